@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+// GRPC_LB_POLICY spreads the calls over the endpoints of GRPC_URL: round_robin (default) or least_request.
+var GRPC_LB_POLICY = os.Getenv("GRPC_LB_POLICY")
+
+// GRPC_RESOLVE_INTERVAL is how often dnspoll:/// targets are looked up again, default 10s.
+var GRPC_RESOLVE_INTERVAL = os.Getenv("GRPC_RESOLVE_INTERVAL")
+
+const leastRequestName = "least_request"
+
+const defaultResolveInterval = 10 * time.Second
+
+func init() {
+	balancer.Register(base.NewBalancerBuilder(leastRequestName, leastRequestPickerBuilder{}, base.Config{HealthCheck: true}))
+}
+
+// ValidateBalancing checks GRPC_LB_POLICY and GRPC_RESOLVE_INTERVAL.
+func ValidateBalancing() error {
+	switch GRPC_LB_POLICY {
+	case "", "round_robin", leastRequestName:
+	default:
+		return fmt.Errorf("unknown GRPC_LB_POLICY %q", GRPC_LB_POLICY)
+	}
+	if GRPC_RESOLVE_INTERVAL != "" {
+		d, err := time.ParseDuration(GRPC_RESOLVE_INTERVAL)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid GRPC_RESOLVE_INTERVAL %q", GRPC_RESOLVE_INTERVAL)
+		}
+	}
+	return nil
+}
+
+func grpcLBPolicy() string {
+	if GRPC_LB_POLICY == "" {
+		return "round_robin"
+	}
+	return GRPC_LB_POLICY
+}
+
+func grpcResolveInterval() time.Duration {
+	d, err := time.ParseDuration(GRPC_RESOLVE_INTERVAL)
+	if err != nil || d <= 0 {
+		return defaultResolveInterval
+	}
+	return d
+}
+
+// leastRequestPickerBuilder picks the endpoint with fewer calls in flight out of two random
+// ones. The counts start over when the set of ready endpoints changes.
+type leastRequestPickerBuilder struct{}
+
+func (leastRequestPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	p := &leastRequestPicker{}
+	for sc := range info.ReadySCs {
+		p.subConns = append(p.subConns, &countedSubConn{SubConn: sc})
+	}
+	return p
+}
+
+type countedSubConn struct {
+	balancer.SubConn
+	inFlight atomic.Int64
+}
+
+type leastRequestPicker struct {
+	subConns []*countedSubConn
+}
+
+func (p *leastRequestPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	sc := p.subConns[rand.Intn(len(p.subConns))]
+	if len(p.subConns) > 1 {
+		other := p.subConns[rand.Intn(len(p.subConns)-1)]
+		// Skip sc itself, so that two different ones are compared.
+		if other == sc {
+			other = p.subConns[len(p.subConns)-1]
+		}
+		if other.inFlight.Load() < sc.inFlight.Load() {
+			sc = other
+		}
+	}
+
+	sc.inFlight.Add(1)
+	return balancer.PickResult{
+		SubConn: sc.SubConn,
+		Done:    func(balancer.DoneInfo) { sc.inFlight.Add(-1) },
+	}, nil
+}
+
+// staticResolverBuilder resolves static:///host1:7777,host2:7777 to the listed endpoints.
+type staticResolverBuilder struct{}
+
+func (staticResolverBuilder) Scheme() string { return "static" }
+
+func (staticResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	var addrs []resolver.Address
+	for _, a := range strings.Split(target.Endpoint(), ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, resolver.Address{Addr: a})
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no endpoints in %s", target.URL.String())
+	}
+	err := cc.UpdateState(resolver.State{Addresses: addrs})
+	if err != nil {
+		return nil, err
+	}
+	return staticResolver{}, nil
+}
+
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+func (staticResolver) Close()                                {}
+
+// dnsPollResolverBuilder resolves dnspoll:///host:port to all IPs of host and looks them up
+// again every interval, so that new replicas get calls without waiting for a failed connection.
+type dnsPollResolverBuilder struct {
+	interval time.Duration
+	lookup   func(ctx context.Context, host string) ([]string, error)
+	resolved *prometheus.GaugeVec
+}
+
+func (*dnsPollResolverBuilder) Scheme() string { return "dnspoll" }
+
+func (b *dnsPollResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	host, port, err := net.SplitHostPort(target.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &dnsPollResolver{
+		builder: b,
+		cc:      cc,
+		target:  target.Endpoint(),
+		host:    host,
+		port:    port,
+		ctx:     ctx,
+		cancel:  cancel,
+		now:     make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+type dnsPollResolver struct {
+	builder    *dnsPollResolverBuilder
+	cc         resolver.ClientConn
+	target     string
+	host, port string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	now    chan struct{}
+	last   []string
+}
+
+func (r *dnsPollResolver) watch() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.builder.interval)
+	defer ticker.Stop()
+
+	r.resolve()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.now:
+		}
+		r.resolve()
+	}
+}
+
+func (r *dnsPollResolver) resolve() {
+	ctx, cancel := context.WithTimeout(r.ctx, r.builder.interval)
+	defer cancel()
+
+	ips, err := r.builder.lookup(ctx, r.host)
+	if err != nil {
+		if r.ctx.Err() == nil {
+			log.Warn().Err(err).Str("target", r.target).Msg("Resolving gRPC endpoints failed")
+			r.cc.ReportError(err)
+		}
+		return
+	}
+	sort.Strings(ips)
+	if equalStrings(ips, r.last) {
+		return
+	}
+	r.last = ips
+
+	addrs := make([]resolver.Address, len(ips))
+	for i, ip := range ips {
+		addrs[i] = resolver.Address{Addr: net.JoinHostPort(ip, r.port)}
+	}
+	log.Info().Str("target", r.target).Strs("endpoints", ips).Msg("Resolved gRPC endpoints")
+	r.builder.resolved.WithLabelValues(r.target).Set(float64(len(addrs)))
+	err = r.cc.UpdateState(resolver.State{Addresses: addrs})
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+	}
+}
+
+// ResolveNow is called by gRPC when a connection failed.
+func (r *dnsPollResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.now <- struct{}{}:
+	default:
+	}
+}
+
+func (r *dnsPollResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// endpointMetrics counts the calls per endpoint, to check how they are spread.
+type endpointMetrics struct {
+	handled  *prometheus.CounterVec
+	resolved *prometheus.GaugeVec
+}
+
+func newEndpointMetrics(reg prometheus.Registerer) *endpointMetrics {
+	m := &endpointMetrics{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_client_endpoint_handled_total",
+			Help: "Finished gRPC calls by the endpoint that handled them, service, method and status code.",
+		}, []string{"endpoint", "grpc_service", "grpc_method", "grpc_code"}),
+		resolved: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_client_resolved_endpoints",
+			Help: "Number of endpoints a dnspoll target resolved to.",
+		}, []string{"target"}),
+	}
+	reg.MustRegister(m.handled, m.resolved)
+	return m
+}
+
+func (m *endpointMetrics) observe(p *peer.Peer, method string, err error) {
+	endpoint := "none"
+	if p.Addr != nil {
+		endpoint = p.Addr.String()
+	}
+	m.handled.WithLabelValues(endpoint, path.Dir(method)[1:], path.Base(method), status.Code(err).String()).Inc()
+}
+
+func (m *endpointMetrics) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p := &peer.Peer{}
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(p))...)
+		m.observe(p, method, err)
+		return err
+	}
+}
+
+func (m *endpointMetrics) StreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		p := &peer.Peer{}
+		stream, err := streamer(ctx, desc, cc, method, append(opts, grpc.Peer(p))...)
+		if err != nil {
+			m.observe(p, method, err)
+			return nil, err
+		}
+		return &observedStream{ClientStream: stream, desc: desc, observe: func(err error) { m.observe(p, method, err) }}, nil
+	}
+}
+
+// observedStream observes the stream once it finished, the peer is only set by then.
+type observedStream struct {
+	grpc.ClientStream
+	desc    *grpc.StreamDesc
+	once    sync.Once
+	observe func(error)
+}
+
+func (s *observedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	// Streams without server streaming finish with their only response.
+	if err != nil || !s.desc.ServerStreams {
+		if err == io.EOF {
+			err = nil
+		}
+		s.once.Do(func() { s.observe(err) })
+	}
+	return err
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"proto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+)
+
+type replicaTrainer struct {
+	proto.UnimplementedTrainerServer
+}
+
+func (replicaTrainer) Stats(ctx context.Context, req *proto.StatsRequest) (*proto.Stats, error) {
+	return &proto.Stats{User: req.User}, nil
+}
+
+func (replicaTrainer) Train(stream proto.Trainer_TrainServer) error {
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&proto.Summary{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// replicas starts n grpcconsumer stand-ins and returns their addresses.
+func replicas(t *testing.T, n int) []string {
+	t.Helper()
+
+	var addrs []string
+	for i := 0; i < n; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := grpc.NewServer()
+		proto.RegisterTrainerServer(srv, replicaTrainer{})
+		healthpb.RegisterHealthServer(srv, health.NewServer())
+		go srv.Serve(lis)
+		t.Cleanup(srv.Stop)
+		addrs = append(addrs, lis.Addr().String())
+	}
+	return addrs
+}
+
+func TestBalancing(t *testing.T) {
+	addrs := replicas(t, 3)
+
+	for _, policy := range []string{"round_robin", "least_request"} {
+		GRPC_LB_POLICY = policy
+		o := NewOutbound(prometheus.NewRegistry())
+		conn, err := grpc.Dial("static:///"+strings.Join(addrs, ","), GRPCDialOptions(insecure.NewCredentials(), o)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := proto.NewTrainerClient(conn)
+
+		handled := func(addr, method string) float64 {
+			return testutil.ToFloat64(o.endpoints.handled.WithLabelValues(addr, "proto.Trainer", method, "OK"))
+		}
+		spread := func() bool {
+			for _, addr := range addrs {
+				if handled(addr, "stats") == 0 {
+					return false
+				}
+			}
+			return true
+		}
+
+		// The first calls go to the replicas that are ready first.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		for !spread() && ctx.Err() == nil {
+			_, err := client.Stats(ctx, &proto.StatsRequest{User: "timw"}, grpc.WaitForReady(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !spread() {
+			t.Errorf("%s: calls were not spread over all replicas", policy)
+		}
+
+		stream, err := client.Train(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = stream.CloseAndRecv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var streams float64
+		for _, addr := range addrs {
+			streams += handled(addr, "train")
+		}
+		if streams != 1 {
+			t.Errorf("%s: %v train streams counted", policy, streams)
+		}
+
+		cancel()
+		conn.Close()
+	}
+	GRPC_LB_POLICY = ""
+}
+
+type fakeSubConn struct {
+	balancer.SubConn
+}
+
+func TestLeastRequestPicker(t *testing.T) {
+	a, b := &fakeSubConn{}, &fakeSubConn{}
+	picker := leastRequestPickerBuilder{}.Build(base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+		a: {Address: resolver.Address{Addr: "a"}},
+		b: {Address: resolver.Address{Addr: "b"}},
+	}})
+
+	first, err := picker.Pick(balancer.PickInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := picker.Pick(balancer.PickInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.SubConn == second.SubConn {
+		t.Fatal("second call went to the busy endpoint")
+	}
+
+	// Once the first call finished, its endpoint has fewer calls in flight.
+	first.Done(balancer.DoneInfo{})
+	third, err := picker.Pick(balancer.PickInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.SubConn != first.SubConn {
+		t.Error("third call went to the busy endpoint")
+	}
+}
+
+// fakeClientConn records the addresses of the resolver.
+type fakeClientConn struct {
+	resolver.ClientConn
+	mu     sync.Mutex
+	states [][]resolver.Address
+}
+
+func (cc *fakeClientConn) UpdateState(s resolver.State) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.states = append(cc.states, s.Addresses)
+	return nil
+}
+
+func (cc *fakeClientConn) ReportError(error) {}
+
+func (cc *fakeClientConn) updates() [][]resolver.Address {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.states
+}
+
+func TestDNSPollResolver(t *testing.T) {
+	var mu sync.Mutex
+	ips := []string{"10.0.0.2", "10.0.0.1"}
+	b := &dnsPollResolverBuilder{
+		interval: time.Hour,
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), ips...), nil
+		},
+		resolved: newEndpointMetrics(prometheus.NewRegistry()).resolved,
+	}
+	cc := &fakeClientConn{}
+	r, err := b.Build(resolver.Target{URL: *mustParseURL(t, "dnspoll:///grpcconsumer:7777")}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	waitFor := func(n int) [][]resolver.Address {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if updates := cc.updates(); len(updates) >= n {
+				return updates
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("%d updates, want %d", len(cc.updates()), n)
+		return nil
+	}
+
+	updates := waitFor(1)
+	if len(updates[0]) != 2 || updates[0][0].Addr != "10.0.0.1:7777" {
+		t.Errorf("resolved %v", updates[0])
+	}
+
+	// Unchanged endpoints are not updated again.
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	mu.Lock()
+	ips = append(ips, "10.0.0.3")
+	mu.Unlock()
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	updates = waitFor(2)
+	if len(updates) != 2 || len(updates[1]) != 3 {
+		t.Errorf("updates %v", updates)
+	}
+	if v := testutil.ToFloat64(b.resolved.WithLabelValues("grpcconsumer:7777")); v != 3 {
+		t.Errorf("resolved endpoints = %v", v)
+	}
+}
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
@@ -4,3 +4,6 @@ import "errors"
 
 var ErrNoUserID = errors.New("no Userid provided in the Form")
 var ErrNoPassWd = errors.New("no password provided in the Form")
+var ErrNoSessionUser = errors.New("no user attached to the session")
+var ErrUnknownUser = errors.New("user does not exist")
+var ErrInvalidSubject = errors.New("subject must be set and must not contain wildcards or spaces")
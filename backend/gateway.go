@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"proto"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NewGateway serves Greeter and Trainer as REST/JSON under /api/v1, see the
+// google.api.http options in the proto files. Requests are forwarded over conn.
+// The gateway must be mounted behind ValidateSession, the user in the path or
+// body is replaced by the user of the session.
+func NewGateway(conn *grpc.ClientConn) (*runtime.ServeMux, error) {
+	mux := runtime.NewServeMux()
+
+	err := proto.RegisterGreeterHandler(context.Background(), mux, conn)
+	if err != nil {
+		return nil, err
+	}
+	err = proto.RegisterTrainerHandlerClient(context.Background(), mux, sessionTrainer{proto.NewTrainerClient(conn)})
+	if err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// sessionTrainer sends every request for the user of the session, so users
+// only read and write their own trainings.
+type sessionTrainer struct {
+	proto.TrainerClient
+}
+
+func sessionUser(ctx context.Context) (string, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "no session")
+	}
+	return user, nil
+}
+
+func (c sessionTrainer) Train(ctx context.Context, opts ...grpc.CallOption) (proto.Trainer_TrainClient, error) {
+	user, err := sessionUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := c.TrainerClient.Train(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sessionTrainStream{stream, user}, nil
+}
+
+func (c sessionTrainer) Stats(ctx context.Context, in *proto.StatsRequest, opts ...grpc.CallOption) (*proto.Stats, error) {
+	user, err := sessionUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	in.User = user
+	return c.TrainerClient.Stats(ctx, in, opts...)
+}
+
+func (c sessionTrainer) ForceSeries(ctx context.Context, in *proto.ForceSeriesRequest, opts ...grpc.CallOption) (*proto.ForceSeries, error) {
+	user, err := sessionUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	in.User = user
+	return c.TrainerClient.ForceSeries(ctx, in, opts...)
+}
+
+type sessionTrainStream struct {
+	proto.Trainer_TrainClient
+	user string
+}
+
+func (s sessionTrainStream) Send(t *proto.Training) error {
+	t.User = s.user
+	return s.Trainer_TrainClient.Send(t)
+}
+
+// OpenAPIGET serves the OpenAPI document of the gateway.
+func (server *Server) OpenAPIGET(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write(proto.OpenAPI)
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGateway(t *testing.T) {
+	s, trainer := trainingServer(t)
+	gw, err := NewGateway(s.grpc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mux.Handle("/api/v1/*", gw)
+
+	// The user of the session, not the one in the path, is asked for.
+	req := httptest.NewRequest("GET", "/api/v1/users/eve/stats?deviceId=device-1", nil)
+	resp := executeRequest(req, s)
+	checkResponseCode(t, http.StatusOK, resp.Code)
+
+	var stats struct {
+		User     string
+		DeviceId string
+	}
+	err = json.Unmarshal(resp.Body.Bytes(), &stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.User != "timw" || stats.DeviceId != "device-1" {
+		t.Errorf("stats for %q %q", stats.User, stats.DeviceId)
+	}
+
+	// gRPC status codes are mapped to HTTP status codes.
+	req = httptest.NewRequest("GET", "/api/v1/users/timw/sessions/1/force", nil)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, s).Code)
+
+	// Client streams are sent as newline delimited JSON.
+	body := `{"DeviceID": "device-1", "User": "eve", "settings": {"maxPos": 10}, "iterations": [{"pos": 1, "force": 4}]}
+{"DeviceID": "device-1", "settings": {"maxPos": 10}, "iterations": [{"pos": 2, "force": 5}]}`
+	req = httptest.NewRequest("POST", "/api/v1/trainings", strings.NewReader(body))
+	resp = executeRequest(req, s)
+	checkResponseCode(t, http.StatusOK, resp.Code)
+	if !strings.Contains(resp.Body.String(), `"force":9`) {
+		t.Errorf("summary = %s", resp.Body.String())
+	}
+	for _, training := range trainer.trainings() {
+		if training.User != "timw" {
+			t.Errorf("training sent for %q, want the user of the session", training.User)
+		}
+	}
+}
+
+func TestGatewayNeedsSession(t *testing.T) {
+	s, _ := trainingServer(t)
+	gw, err := NewGateway(s.grpc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without ValidateSession there is no user, which is rejected too.
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/", gw)
+	req := httptest.NewRequest("GET", "/api/v1/users/timw/stats", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	checkResponseCode(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestOpenAPIGET(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	resp := executeRequest(req, &server)
+	checkResponseCode(t, http.StatusOK, resp.Code)
+
+	var doc struct {
+		Paths map[string]any
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.Paths["/api/v1/users/{user}/stats"]; !ok {
+		t.Errorf("paths = %v", doc.Paths)
+	}
+}
@@ -1,29 +1,179 @@
 package main
 
 import (
+	"certs"
 	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"proto"
+	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/golang-jwt/jwt/v4"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health" // client side health checking
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
+// grpcServiceConfig spreads the calls over the endpoints by policy. It watches the grpc.health.v1
+// status of every grpcconsumer, so that calls fail fast while it is NOT_SERVING, and retries calls
+// without side effects on UNAVAILABLE. Health checking does not work with the pick_first policy.
+func grpcServiceConfig(policy string) string {
+	return fmt.Sprintf(`{
+	"loadBalancingConfig": [{%q: {}}],
+	"healthCheckConfig": {"serviceName": ""},
+	"methodConfig": [{
+		"name": [
+			{"service": "tutorial.Greeter"},
+			{"service": "proto.Trainer", "method": "stats"},
+			{"service": "proto.Trainer", "method": "forceSeries"}
+		],
+		"retryPolicy": {
+			"maxAttempts": 4,
+			"initialBackoff": "0.1s",
+			"maxBackoff": "1s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`, policy)
+}
+
+// GRPC_TLS_CA enables TLS to grpcconsumer, its certificate has to be signed by the CA.
+var GRPC_TLS_CA = os.Getenv("GRPC_TLS_CA")
+
+// The certificate of the backend for mutual TLS.
+var GRPC_TLS_CERT = os.Getenv("GRPC_TLS_CERT")
+var GRPC_TLS_KEY = os.Getenv("GRPC_TLS_KEY")
+
+// GRPC_TLS_SERVER_NAME overrides the name verified in the certificate of grpcconsumer,
+// by default the host of GRPC_URL.
+var GRPC_TLS_SERVER_NAME = os.Getenv("GRPC_TLS_SERVER_NAME")
+
+// GRPC_AUTH_SECRET signs the tokens with the user of the session that authenticate calls at grpcconsumer.
+var GRPC_AUTH_SECRET = os.Getenv("GRPC_AUTH_SECRET")
+
+// grpcTokenTTL is how long the token of a call is valid.
+const grpcTokenTTL = time.Minute
+
+func init() {
+	grpc_prometheus.EnableClientHandlingTimeHistogram()
+}
+
+// CallCredentials forward the user of the session as HS256 bearer token in the authorization
+// metadata. Calls without a user are sent without credentials, so grpcconsumer only allows
+// them what it allows anonymous callers.
+type CallCredentials struct {
+	Secret string
+}
+
+func (c CallCredentials) outgoing(ctx context.Context) (context.Context, error) {
+	if user, ok := UserFromContext(ctx); ok && c.Secret != "" {
+		now := time.Now()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Subject:   user,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(grpcTokenTTL)),
+		}).SignedString([]byte(c.Secret))
+		if err != nil {
+			return nil, err
+		}
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token), nil
+	}
+	return ctx, nil
+}
+
+func (c CallCredentials) Unary() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := c.outgoing(ctx)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (c CallCredentials) Stream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := c.outgoing(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// GRPCCredentials are plaintext without GRPC_TLS_CA. The certificates are reloaded
+// every 10s if they changed until ctx is canceled.
+func GRPCCredentials(ctx context.Context) (credentials.TransportCredentials, error) {
+	if GRPC_TLS_CA == "" {
+		return insecure.NewCredentials(), nil
+	}
+	r, err := certs.NewReloader(GRPC_TLS_CERT, GRPC_TLS_KEY, GRPC_TLS_CA)
+	if err != nil {
+		return nil, err
+	}
+	go r.Watch(ctx, 10*time.Second)
+	return credentials.NewTLS(r.ClientConfig(GRPC_TLS_SERVER_NAME)), nil
+}
+
+// GRPCDialOptions are the options of the connection to grpcconsumer. GRPC_URL may list
+// several endpoints with static:///host1:7777,host2:7777 or resolve all replicas with
+// dnspoll:///grpcconsumer:7777. Calls are traced, counted in the grpc_client_* metrics,
+// bounded by the outbound target and authenticated.
+func GRPCDialOptions(creds credentials.TransportCredentials, outbound *Outbound) []grpc.DialOption {
+	auth := CallCredentials{Secret: GRPC_AUTH_SECRET}
+	dnspoll := &dnsPollResolverBuilder{
+		interval: grpcResolveInterval(),
+		lookup:   net.DefaultResolver.LookupHost,
+		resolved: outbound.endpoints.resolved,
+	}
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithResolvers(staticResolverBuilder{}, dnspoll),
+		grpc.WithChainUnaryInterceptor(
+			otelgrpc.UnaryClientInterceptor(),
+			grpc_prometheus.UnaryClientInterceptor,
+			outbound.GRPC.UnaryInterceptor(),
+			outbound.endpoints.UnaryInterceptor(),
+			auth.Unary(),
+		),
+		grpc.WithChainStreamInterceptor(
+			otelgrpc.StreamClientInterceptor(),
+			grpc_prometheus.StreamClientInterceptor,
+			outbound.GRPC.StreamInterceptor(),
+			outbound.endpoints.StreamInterceptor(),
+			auth.Stream(),
+		),
+		grpc.WithDefaultServiceConfig(grpcServiceConfig(grpcLBPolicy())),
+	}
+}
+
 func (s *Server) CallGRPCPost(w http.ResponseWriter, r *http.Request) {
 	client := proto.NewGreeterClient(s.grpc)
 
-	reply, err := client.SayHello(context.Background(), &proto.HelloRequest{
+	reply, err := client.SayHello(r.Context(), &proto.HelloRequest{
 		Name: "SHAALALALL",
 	})
 	if err != nil {
-		s.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
 		log.Warn().Err(err).Caller().Msg("")
+		s.SendGRPCError(w, r, err)
+		return
 	}
 
 	bytes, err := protojson.Marshal(reply)
 	if err != nil {
 		s.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
 		log.Warn().Err(err).Caller().Msg("")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"certs"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"proto"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// flakyTrainer fails every other Stats call.
+type flakyTrainer struct {
+	proto.UnimplementedTrainerServer
+	calls atomic.Int64
+}
+
+func (f *flakyTrainer) Stats(ctx context.Context, req *proto.StatsRequest) (*proto.Stats, error) {
+	if f.calls.Add(1)%2 == 1 {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &proto.Stats{User: req.User}, nil
+}
+
+func dialHealthChecked(t *testing.T) (proto.TrainerClient, *flakyTrainer, *health.Server) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	trainer := &flakyTrainer{}
+	hs := health.NewServer()
+	proto.RegisterTrainerServer(srv, trainer)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	opts := append(GRPCDialOptions(insecure.NewCredentials(), NewOutbound(prometheus.NewRegistry())),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewTrainerClient(conn), trainer, hs
+}
+
+func TestGRPCRetry(t *testing.T) {
+	client, trainer, _ := dialHealthChecked(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := client.Stats(ctx, &proto.StatsRequest{User: "timw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := trainer.calls.Load(); n != 2 {
+		t.Errorf("%d attempts, want 2", n)
+	}
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	client, trainer, hs := dialHealthChecked(t)
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := client.Stats(ctx, &proto.StatsRequest{User: "timw"})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want Unavailable", err)
+	}
+	if n := trainer.calls.Load(); n != 0 {
+		t.Errorf("%d calls reached the server while NOT_SERVING", n)
+	}
+
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	_, err = client.Stats(ctx, &proto.StatsRequest{User: "timw"}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Errorf("still failing once SERVING: %v", err)
+	}
+}
+
+// counterValue sums the samples of a counter in the default registry that have the given labels.
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if v, ok := labels[l.GetName()]; ok && v != l.GetValue() {
+					continue metrics
+				}
+			}
+			sum += m.GetCounter().GetValue()
+		}
+	}
+	return sum
+}
+
+func TestGRPCClientMetrics(t *testing.T) {
+	client, _, _ := dialHealthChecked(t)
+	labels := map[string]string{"grpc_service": "proto.Trainer", "grpc_method": "stats", "grpc_code": "OK"}
+	before := counterValue(t, "grpc_client_handled_total", labels)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := client.Stats(ctx, &proto.StatsRequest{User: "timw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry within the call is not counted separately.
+	if n := counterValue(t, "grpc_client_handled_total", labels) - before; n != 1 {
+		t.Errorf("handled %v calls, want 1", n)
+	}
+}
+
+func TestGRPCMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := certs.NewCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, data []byte) string {
+		file := filepath.Join(dir, name)
+		err := os.WriteFile(file, data, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	issue := func(name string, sans ...string) (string, string) {
+		cert, key, err := ca.Issue(name, sans, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return write(name+".pem", cert), write(name+"-key.pem", key)
+	}
+
+	caFile := write("ca.pem", ca.PEM())
+	serverCert, serverKey := issue("grpcconsumer", "grpcconsumer")
+	server, err := certs.NewReloader(serverCert, serverKey, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(server.ServerConfig())))
+	proto.RegisterTrainerServer(srv, &flakyTrainer{})
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	GRPC_TLS_CA = caFile
+	GRPC_TLS_CERT, GRPC_TLS_KEY = issue("backend", "spiffe://test/backend")
+	GRPC_TLS_SERVER_NAME = "grpcconsumer"
+	t.Cleanup(func() { GRPC_TLS_CA, GRPC_TLS_CERT, GRPC_TLS_KEY, GRPC_TLS_SERVER_NAME = "", "", "", "" })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	creds, err := GRPCCredentials(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.Dial(lis.Addr().String(), GRPCDialOptions(creds, NewOutbound(prometheus.NewRegistry()))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = proto.NewTrainerClient(conn).Stats(ctx, &proto.StatsRequest{User: "timw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCallCredentials(t *testing.T) {
+	creds := CallCredentials{Secret: "secret"}
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	ctx := context.WithValue(context.Background(), userContextKey{}, "timw")
+	err := creds.Unary()(ctx, "/proto.Trainer/stats", nil, nil, nil, invoker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := md.Get("authorization")
+	if len(auth) != 1 || !strings.HasPrefix(auth[0], "Bearer ") {
+		t.Fatalf("metadata with session = %v", md)
+	}
+	claims := &jwt.RegisteredClaims{}
+	_, err = jwt.ParseWithClaims(strings.TrimPrefix(auth[0], "Bearer "), claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "timw" || claims.ExpiresAt == nil {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	err = creds.Unary()(context.Background(), "/tutorial.Greeter/SayHello", nil, nil, nil, invoker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Requests of users without session are anonymous, not sent with a service key.
+	if len(md) != 0 {
+		t.Errorf("metadata without session = %v", md)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// JetStreamConfig describes the stream persisting the NATS subjects.
+type JetStreamConfig struct {
+	Enabled  bool
+	Stream   string
+	Subjects []string
+	MaxAge   time.Duration
+	MaxMsgs  int64
+	// DedupWindow is how long message ids are remembered to drop duplicates.
+	DedupWindow time.Duration
+}
+
+// LoadJetStreamConfig reads NATS_JETSTREAM, NATS_STREAM, NATS_SUBJECTS, NATS_MAX_AGE and NATS_MAX_MSGS.
+func LoadJetStreamConfig() (JetStreamConfig, error) {
+	cfg := JetStreamConfig{
+		Enabled:     os.Getenv("NATS_JETSTREAM") == "true",
+		Stream:      os.Getenv("NATS_STREAM"),
+		MaxAge:      24 * time.Hour,
+		MaxMsgs:     100000,
+		DedupWindow: 2 * time.Minute,
+	}
+	if cfg.Stream == "" {
+		cfg.Stream = "FOO"
+	}
+	for _, subj := range strings.Split(os.Getenv("NATS_SUBJECTS"), ",") {
+		if subj = strings.TrimSpace(subj); subj != "" {
+			cfg.Subjects = append(cfg.Subjects, subj)
+		}
+	}
+	if len(cfg.Subjects) == 0 {
+		cfg.Subjects = []string{"foo"}
+	}
+
+	if val := os.Getenv("NATS_MAX_AGE"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return cfg, fmt.Errorf("NATS_MAX_AGE: %v", err)
+		}
+		cfg.MaxAge = d
+	}
+	if val := os.Getenv("NATS_MAX_MSGS"); val != "" {
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("NATS_MAX_MSGS: %v", err)
+		}
+		cfg.MaxMsgs = n
+	}
+	return cfg, nil
+}
+
+// EnsureStream creates the stream or updates its subjects and limits.
+func EnsureStream(js nats.JetStreamContext, cfg JetStreamConfig) (*nats.StreamInfo, error) {
+	streamCfg := &nats.StreamConfig{
+		Name:       cfg.Stream,
+		Subjects:   cfg.Subjects,
+		Retention:  nats.LimitsPolicy,
+		MaxAge:     cfg.MaxAge,
+		MaxMsgs:    cfg.MaxMsgs,
+		Storage:    nats.FileStorage,
+		Duplicates: cfg.DedupWindow,
+	}
+
+	_, err := js.StreamInfo(cfg.Stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		log.Info().Str("stream", cfg.Stream).Strs("subjects", cfg.Subjects).Msg("Creating JetStream stream")
+		return js.AddStream(streamCfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return js.UpdateStream(streamCfg)
+}
+
+// ConnectJetStream returns nil if JetStream is disabled.
+func ConnectJetStream(nc *nats.Conn, cfg JetStreamConfig) (nats.JetStreamContext, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	_, err = EnsureStream(js, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to ensure stream %s: %v", cfg.Stream, err)
+	}
+
+	log.Info().Msg("Successfully setup JetStream")
+	return js, nil
+}
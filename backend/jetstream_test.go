@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// runNatsServer starts an embedded nats-server with JetStream on a random port.
+func runNatsServer(t *testing.T) *nats.Conn {
+	t.Helper()
+
+	ns, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      natsserver.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats-server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+func TestEnsureStream(t *testing.T) {
+	nc := runNatsServer(t)
+	cfg := JetStreamConfig{Enabled: true, Stream: "FOO", Subjects: []string{"foo"}, MaxAge: time.Hour, MaxMsgs: 10, DedupWindow: time.Minute}
+
+	js, err := ConnectJetStream(nc, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second call updates the existing stream.
+	cfg.Subjects = []string{"foo", "bar"}
+	info, err := EnsureStream(js, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Config.Subjects) != 2 || info.Config.MaxMsgs != 10 {
+		t.Errorf("stream config = %+v, want subjects foo, bar and 10 messages", info.Config)
+	}
+}
+
+func TestJetStreamDropsDuplicates(t *testing.T) {
+	nc := runNatsServer(t)
+	cfg := JetStreamConfig{Enabled: true, Stream: "FOO", Subjects: []string{"foo"}, MaxAge: time.Hour, MaxMsgs: 10, DedupWindow: time.Minute}
+
+	js, err := ConnectJetStream(nc, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		msg := nats.NewMsg("foo")
+		msg.Header.Set(nats.MsgIdHdr, "same-id")
+		msg.Data = []byte("hello")
+
+		ack, err := js.PublishMsg(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ack.Duplicate != (i == 1) {
+			t.Errorf("publish %d: duplicate = %v", i, ack.Duplicate)
+		}
+	}
+
+	info, err := js.StreamInfo("FOO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("stream holds %d messages, want 1", info.State.Msgs)
+	}
+}
+
+func TestConnectJetStreamDisabled(t *testing.T) {
+	js, err := ConnectJetStream(nil, JetStreamConfig{})
+	if err != nil || js != nil {
+		t.Errorf("ConnectJetStream = %v, %v, want nil, nil when disabled", js, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"math/rand"
+	"messaging"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,7 +17,6 @@ import (
 	"github.com/go-redis/redis/v9"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nats-io/nats.go"
-	"github.com/nsqio/go-nsq"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"google.golang.org/grpc"
@@ -31,8 +31,7 @@ import (
 
 var CacheURL = os.Getenv("CACHE_URL")
 var PgURL = os.Getenv("DATABASE_URL")
-var NSQD = os.Getenv("NSQ_DEMON")
-var Jaeger = os.Getenv("JAEGER_URL") //Port is 14268
+var NSQD = os.Getenv("NSQ_DEMON") // Comma separated, e.g. nsqd1,nsqd2:4150
 var TracingApp = os.Getenv("TRACING_URL")
 var NATS_URL = os.Getenv("NATS_URL")
 var GRPC_URL = os.Getenv("GRPC_URL")
@@ -47,9 +46,6 @@ func ValidateEnvVariables() {
 	if NSQD == "" {
 		log.Fatal().Msg("NSQ_DEMON not set!")
 	}
-	if Jaeger == "" {
-		log.Fatal().Msg("JAEGER_URL not set!")
-	}
 	if TracingApp == "" {
 		log.Fatal().Msg("TRACING_URL not set!")
 	}
@@ -59,16 +55,32 @@ func ValidateEnvVariables() {
 	if GRPC_URL == "" {
 		log.Fatal().Msg("GRPC_URL not set!")
 	}
+	err := ValidateBalancing()
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
 }
 
 type Server struct {
 	redis *redis.Client
 	pg    *pgxpool.Pool
-	nsq   *nsq.Producer
+	nsq   *ProducerPool
 	mux   *chi.Mux
 	tp    *trace.TracerProvider
 	nats  *nats.Conn
-	grpc  *grpc.ClientConn
+	// messages publishes to the broker routed for the topic.
+	messages messaging.Publisher
+	// js is nil unless NATS_JETSTREAM is enabled.
+	js   nats.JetStreamContext
+	grpc *grpc.ClientConn
+	// random keeps the fullRandom streams opened by /stream/random.
+	random *RandomStreams
+	// gateway serves the gRPC services as REST/JSON under /api/v1.
+	gateway http.Handler
+	// outbound bounds the calls to grpcconsumer, tracingApp and NATS requests.
+	outbound *Outbound
+
+	outbox *OutboxRelay
 }
 
 func (server *Server) SendError(w http.ResponseWriter, r *http.Request) {
@@ -155,6 +167,9 @@ func (server *Server) JsonPage(w http.ResponseWriter, r *http.Request) {
 
 func (server *Server) Shutdown(context.Context) error {
 
+	// Stop relaying before the connections it uses are closed.
+	server.outbox.Stop()
+
 	server.pg.Close()
 
 	err := server.redis.Close()
@@ -169,6 +184,8 @@ func (server *Server) Shutdown(context.Context) error {
 		return err
 	}
 
+	// Waits for pending async publishes.
+	server.nsq.Stop()
 	server.nats.Close()
 	server.grpc.Close()
 
@@ -213,7 +230,15 @@ func AttachAllPaths(server *Server) {
 	server.mux.Post("/logout", server.LogoutUserPOST)
 
 	server.mux.Post("/nats", server.NatsPost)
+	server.mux.Post("/nats/request", server.NatsRequestPOST)
 	server.mux.Post("/grpc", server.CallGRPCPost)
+	server.mux.Get("/stream/random", server.RandomStreamGET)
+	server.mux.Post("/stream/random", server.RandomStreamPOST)
+
+	server.mux.Get("/api/openapi.json", server.OpenAPIGET)
+	if server.gateway != nil {
+		server.mux.Handle("/api/v1/*", server.ValidateSession(server.gateway))
+	}
 
 	// Makes it far easier to protect all underlying Handlers
 	protectedRouter := chi.NewRouter()
@@ -221,6 +246,10 @@ func AttachAllPaths(server *Server) {
 	protectedRouter.Get("/", server.ProduceToNSQGET)
 	protectedRouter.Post("/", server.ProduceToNSQPOST)
 	protectedRouter.Get("/sth", server.JsonPage)
+	protectedRouter.Post("/delete", server.DeleteUserPOST)
+	protectedRouter.Post("/training", server.TrainingPOST)
+	protectedRouter.Get("/training/stats", server.TrainingStatsGET)
+	protectedRouter.Get("/training/sessions/{id}/force", server.TrainingForceGET)
 
 	server.mux.Mount("/protected", protectedRouter)
 
@@ -237,6 +266,8 @@ func main() {
 
 	AttachAllPaths(server)
 
+	server.outbox.Start()
+
 	rand.Seed(time.Now().Unix())
 
 	// Listen for syscall signals for process to interrupt/quit
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"messaging"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewMessagePublisher routes topics to NSQ, NATS or JetStream as
+// messaging.LoadRoutes says and traces, counts and logs every publish.
+// Publishes with a messaging.WithAsync context are asynchronous if nsq supports it.
+func NewMessagePublisher(nsq messaging.NSQProducer, nc *nats.Conn, js nats.JetStreamContext, reg prometheus.Registerer) (messaging.Publisher, error) {
+	brokers := map[string]messaging.Publisher{
+		"nsq":  messaging.NewNSQPublisher(nsq),
+		"nats": messaging.NewNATS(nc),
+	}
+	if js != nil {
+		brokers["jetstream"] = messaging.NewJetStream(nc, js)
+	}
+
+	routes, err := messaging.LoadRoutes(js != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := messaging.NewRouter(brokers, routes)
+	if err != nil {
+		return nil, err
+	}
+	for topic, names := range routes {
+		log.Info().Str("topic", topic).Strs("brokers", names).Msg("Message route")
+	}
+
+	metrics := messaging.NewMetrics(reg, service)
+	return messaging.WrapPublisher(router,
+		messaging.PublishTracing(),
+		metrics.Publish(),
+		messaging.PublishLogging(log.Logger),
+	), nil
+}
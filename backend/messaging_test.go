@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"messaging"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMessageRoutesOverride(t *testing.T) {
+	t.Setenv("MESSAGING_ROUTES", "foo=nsq")
+
+	producer := &fakeProducer{}
+	p, err := NewMessagePublisher(producer, nil, nil, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Publish(context.Background(), "foo", messaging.NewMessage("1", []byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if producer.count() != 1 {
+		t.Errorf("foo was not switched to NSQ")
+	}
+}
+
+func TestMessageRoutesUnknownBroker(t *testing.T) {
+	// JetStream is disabled, so it cannot be routed to.
+	t.Setenv("MESSAGING_ROUTES", "foo=jetstream")
+	_, err := NewMessagePublisher(&fakeProducer{}, nil, nil, prometheus.NewRegistry())
+	if err == nil {
+		t.Error("route to disabled JetStream accepted")
+	}
+}
+
+// Async publishes go through the router to the pool's async publish.
+func TestMessagePublisherAsync(t *testing.T) {
+	pool := newTestPool(t, PoolConfig{AsyncBuffer: 1}, &fakeProducer{})
+	p, err := NewMessagePublisher(pool, nil, nil, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.async <- struct{}{}
+	ctx := messaging.WithAsync(context.Background())
+	err = p.Publish(ctx, "default", messaging.NewMessage("", []byte("hello")))
+	if !errors.Is(err, ErrPublishBufferFull) {
+		t.Errorf("got %v, want ErrPublishBufferFull", err)
+	}
+	<-pool.async
+
+	err = p.Publish(context.Background(), "default", messaging.NewMessage("", []byte("hello")))
+	if err != nil {
+		t.Errorf("sync publish failed: %v", err)
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"messaging"
 	"net/http"
 	"proto"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/google/uuid"
 	pb "google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -31,7 +34,9 @@ func (server *Server) NatsPost(w http.ResponseWriter, r *http.Request) {
 		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
 	}
 
-	err = server.nats.Publish("foo", msgMarsh)
+	// The id lets consumers skip the message if it is ever published twice.
+	// Published to JetStream if enabled, see messaging.DefaultRoutes.
+	err = server.messages.Publish(r.Context(), "foo", messaging.NewMessage(uuid.NewString(), msgMarsh))
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("")
 		server.SendErrorMessage(w, r, 404, err.Error())
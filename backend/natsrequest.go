@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultRequestTimeout = time.Second
+	maxRequestTimeout     = 30 * time.Second
+	// maxRequestBody bounds the payload forwarded to NATS.
+	maxRequestBody = 64 << 10
+)
+
+// NatsReply is one answer to a NATS request.
+type NatsReply struct {
+	Data string `json:"data"`
+	// Latency is the time from sending the request until the reply arrived.
+	Latency float64 `json:"latency_ms"`
+}
+
+// NatsRequestResult is the JSON answer of /nats/request.
+type NatsRequestResult struct {
+	Subject string      `json:"subject"`
+	Replies []NatsReply `json:"replies"`
+}
+
+// NatsRequest sends data to subj and waits for the first reply until ctx expires.
+// The trace context of ctx is sent along in the NATS headers.
+func NatsRequest(ctx context.Context, nc *nats.Conn, subj string, data []byte) (NatsReply, error) {
+	ctx, span := startRequestSpan(ctx, subj, "request")
+	defer span.End()
+
+	msg := newRequestMsg(ctx, subj, data)
+	start := time.Now()
+	resp, err := nc.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return NatsReply{}, err
+	}
+
+	span.SetAttributes(attribute.Int("messaging.nats.replies", 1))
+	return NatsReply{Data: string(resp.Data), Latency: msSince(start)}, nil
+}
+
+// NatsScatterGather sends data to subj and collects every reply until ctx expires
+// or max replies arrived. max <= 0 collects until ctx expires.
+// It returns nats.ErrNoResponders if nobody listens on subj.
+func NatsScatterGather(ctx context.Context, nc *nats.Conn, subj string, data []byte, max int) ([]NatsReply, error) {
+	ctx, span := startRequestSpan(ctx, subj, "scatter-gather")
+	defer span.End()
+
+	replies, err := scatterGather(ctx, nc, subj, data, max)
+	span.SetAttributes(attribute.Int("messaging.nats.replies", len(replies)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return replies, err
+}
+
+func scatterGather(ctx context.Context, nc *nats.Conn, subj string, data []byte, max int) ([]NatsReply, error) {
+	inbox := nc.NewInbox()
+	sub, err := nc.SubscribeSync(inbox)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	msg := newRequestMsg(ctx, subj, data)
+	msg.Reply = inbox
+
+	start := time.Now()
+	err = nc.PublishMsg(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := []NatsReply{}
+	for max <= 0 || len(replies) < max {
+		resp, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			// The window is over.
+			if ctx.Err() != nil {
+				break
+			}
+			return replies, err
+		}
+		if isNoResponders(resp) {
+			return replies, nats.ErrNoResponders
+		}
+		replies = append(replies, NatsReply{Data: string(resp.Data), Latency: msSince(start)})
+	}
+	return replies, nil
+}
+
+// isNoResponders detects the status message the server sends if no one subscribed to the subject.
+func isNoResponders(m *nats.Msg) bool {
+	return len(m.Data) == 0 && m.Header.Get("Status") == "503"
+}
+
+// newRequestMsg creates a message carrying the trace context of ctx in its headers.
+func newRequestMsg(ctx context.Context, subj string, data []byte) *nats.Msg {
+	msg := nats.NewMsg(subj)
+	msg.Data = data
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	return msg
+}
+
+func startRequestSpan(ctx context.Context, subj, kind string) (context.Context, trace.Span) {
+	return otel.Tracer("nats").Start(ctx, subj+" "+kind,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination", subj),
+		))
+}
+
+func msSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// NatsRequestPOST forwards the request body to a NATS subject and returns the replies as JSON.
+//
+// Query parameters:
+//   - subject: where to send the request, must not contain wildcards
+//   - timeout: how long to wait, e.g. 500ms, default 1s
+//   - gather: if true, collect all replies until timeout instead of only the first one
+//   - max: stop gathering after this many replies
+func (server *Server) NatsRequestPOST(w http.ResponseWriter, r *http.Request) {
+	subj := r.URL.Query().Get("subject")
+	if subj == "" || strings.ContainsAny(subj, "*> \t") {
+		server.SendErrorMessage(w, r, http.StatusBadRequest, ErrInvalidSubject.Error())
+		return
+	}
+
+	timeout := defaultRequestTimeout
+	if val := r.URL.Query().Get("timeout"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 || d > maxRequestTimeout {
+			server.SendErrorMessage(w, r, http.StatusBadRequest, "timeout must be a duration up to 30s")
+			return
+		}
+		timeout = d
+	}
+
+	gather := r.URL.Query().Get("gather") == "true"
+	max := 0
+	if val := r.URL.Query().Get("max"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			server.SendErrorMessage(w, r, http.StatusBadRequest, "max must be a positive number")
+			return
+		}
+		max = n
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	// Requests are not retried, the responders may not be idempotent.
+	result := NatsRequestResult{Subject: subj}
+	err = server.outbound.NATS.Do(ctx, false, func(ctx context.Context) error {
+		var err error
+		if gather {
+			result.Replies, err = NatsScatterGather(ctx, server.nats, subj, data, max)
+		} else {
+			var reply NatsReply
+			reply, err = NatsRequest(ctx, server.nats, subj, data)
+			result.Replies = []NatsReply{reply}
+		}
+		return err
+	})
+
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		server.SendErrorMessage(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, nats.ErrNoResponders):
+		server.SendErrorMessage(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, nats.ErrTimeout):
+		server.SendErrorMessage(w, r, http.StatusGatewayTimeout, "no reply within "+timeout.String())
+		return
+	case err != nil:
+		log.Warn().Err(err).Caller().Msg("")
+		server.SendErrorMessage(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		server.SendError(w, r)
+		log.Warn().Err(err).Caller().Msg("")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(bytes)
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+	}
+}
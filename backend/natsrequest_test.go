@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// respond answers every request on subj with reply.
+func respond(t *testing.T, nc *nats.Conn, subj, reply string) {
+	t.Helper()
+	_, err := nc.Subscribe(subj, func(m *nats.Msg) {
+		m.Respond([]byte(reply))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc.Flush()
+}
+
+func TestNatsRequestPropagatesTrace(t *testing.T) {
+	nc := runNatsServer(t)
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	traceparent := make(chan string, 1)
+	_, err := nc.Subscribe("greet", func(m *nats.Msg) {
+		traceparent <- propagation.HeaderCarrier(m.Header).Get("traceparent")
+		m.Respond([]byte("hi"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	reply, err := NatsRequest(ctx, nc, "greet", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Data != "hi" {
+		t.Errorf("reply = %q, want hi", reply.Data)
+	}
+
+	got := <-traceparent
+	traceID := trace.SpanContextFromContext(ctx).TraceID().String()
+	if !strings.Contains(got, traceID) {
+		t.Errorf("traceparent %q does not carry trace %s", got, traceID)
+	}
+}
+
+func TestNatsScatterGather(t *testing.T) {
+	nc := runNatsServer(t)
+	for _, reply := range []string{"1", "2", "3"} {
+		respond(t, nc, "greet", reply)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	replies, err := NatsScatterGather(ctx, nc, "greet", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 3 {
+		t.Errorf("got %d replies, want 3", len(replies))
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	replies, err = NatsScatterGather(ctx, nc, "greet", nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 2 {
+		t.Errorf("got %d replies with max 2", len(replies))
+	}
+}
+
+func TestNatsScatterGatherNoResponders(t *testing.T) {
+	nc := runNatsServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := NatsScatterGather(ctx, nc, "nobody", nil, 0)
+	if !errors.Is(err, nats.ErrNoResponders) {
+		t.Errorf("got %v, want ErrNoResponders", err)
+	}
+}
+
+func TestNatsRequestPOST(t *testing.T) {
+	nc := runNatsServer(t)
+	respond(t, nc, "greet", "hi")
+	s := &Server{nats: nc, outbound: NewOutbound(prometheus.NewRegistry())}
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"subject=greet", http.StatusOK},
+		{"subject=greet&gather=true&timeout=100ms", http.StatusOK},
+		{"subject=nobody", http.StatusServiceUnavailable},
+		{"subject=foo.*", http.StatusBadRequest},
+		{"subject=greet&timeout=1h", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/nats/request?"+tt.query, strings.NewReader("hello"))
+		rec := httptest.NewRecorder()
+		s.NatsRequestPOST(rec, req)
+
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d: %s", tt.query, rec.Code, tt.code, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var result NatsRequestResult
+		err := json.Unmarshal(rec.Body.Bytes(), &result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Replies) != 1 || result.Replies[0].Data != "hi" {
+			t.Errorf("%s: replies = %+v, want one hi", tt.query, result.Replies)
+		}
+	}
+}
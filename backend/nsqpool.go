@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ErrNoProducer = errors.New("no nsqd configured")
+var ErrPublishBufferFull = errors.New("async publish buffer is full")
+
+// nsqPublisher is the part of *nsq.Producer the pool uses.
+type nsqPublisher interface {
+	Publish(topic string, body []byte) error
+	PublishAsync(topic string, body []byte, doneChan chan *nsq.ProducerTransaction, args ...interface{}) error
+	Stop()
+}
+
+// PoolConfig tunes retries and failover of the ProducerPool.
+type PoolConfig struct {
+	// Retries is how often a publish that failed on all nsqd is tried again.
+	Retries    int
+	RetryDelay time.Duration
+	// DownFor is how long a failed nsqd is skipped.
+	DownFor time.Duration
+	// AsyncBuffer bounds how many async publishes may be pending.
+	AsyncBuffer int
+}
+
+var DefaultPoolConfig = PoolConfig{
+	Retries:     2,
+	RetryDelay:  100 * time.Millisecond,
+	DownFor:     5 * time.Second,
+	AsyncBuffer: 1000,
+}
+
+type poolMember struct {
+	addr     string
+	producer nsqPublisher
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func (m *poolMember) available(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !now.Before(m.downUntil)
+}
+
+func (m *poolMember) markDown(d time.Duration) {
+	m.mu.Lock()
+	m.downUntil = time.Now().Add(d)
+	m.mu.Unlock()
+}
+
+func (m *poolMember) markUp() {
+	m.mu.Lock()
+	m.downUntil = time.Time{}
+	m.mu.Unlock()
+}
+
+// ProducerPool spreads publishes round robin over several nsqd.
+// A nsqd that fails is skipped for a while and the publish fails over to the next one.
+type ProducerPool struct {
+	members []*poolMember
+	cfg     PoolConfig
+	next    uint32
+
+	async chan struct{}
+	wg    sync.WaitGroup
+
+	latency  *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	up       *prometheus.GaugeVec
+	pending  prometheus.Gauge
+	rejected prometheus.Counter
+}
+
+// NewProducerPool creates one producer per nsqd address and registers the pool metrics with reg.
+// Addresses without port get the default nsqd port 4150.
+func NewProducerPool(addrs []string, cfg PoolConfig, reg prometheus.Registerer) (*ProducerPool, error) {
+	var members []*poolMember
+	for _, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "4150")
+		}
+		producer, err := nsq.NewProducer(addr, nsq.NewConfig())
+		if err != nil {
+			return nil, fmt.Errorf("unable to create producer for %s: %v", addr, err)
+		}
+		members = append(members, &poolMember{addr: addr, producer: producer})
+	}
+	return newProducerPool(members, cfg, reg)
+}
+
+func newProducerPool(members []*poolMember, cfg PoolConfig, reg prometheus.Registerer) (*ProducerPool, error) {
+	if len(members) == 0 {
+		return nil, ErrNoProducer
+	}
+
+	p := &ProducerPool{
+		members: members,
+		cfg:     cfg,
+		async:   make(chan struct{}, cfg.AsyncBuffer),
+	}
+
+	p.latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "nsq_publish_duration_seconds",
+		Help:        "How long publishing to a nsqd took, partitioned by nsqd.",
+		ConstLabels: prometheus.Labels{"service": service},
+		Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 12),
+	}, []string{"nsqd"})
+	p.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "nsq_publish_errors_total",
+		Help:        "How many publishes to a nsqd failed, partitioned by nsqd.",
+		ConstLabels: prometheus.Labels{"service": service},
+	}, []string{"nsqd"})
+	p.up = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "nsq_producer_up",
+		Help:        "Whether the nsqd is used for publishing (1) or skipped after a failure (0).",
+		ConstLabels: prometheus.Labels{"service": service},
+	}, []string{"nsqd"})
+	p.pending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "nsq_publish_async_pending",
+		Help:        "How many async publishes have not been confirmed by nsqd yet.",
+		ConstLabels: prometheus.Labels{"service": service},
+	})
+	p.rejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "nsq_publish_async_rejected_total",
+		Help:        "How many async publishes were rejected because the buffer was full.",
+		ConstLabels: prometheus.Labels{"service": service},
+	})
+
+	for _, c := range []prometheus.Collector{p.latency, p.errors, p.up, p.pending, p.rejected} {
+		err := reg.Register(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range members {
+		p.up.WithLabelValues(m.addr).Set(1)
+	}
+
+	return p, nil
+}
+
+// Publish publishes synchronously, failing over to the other nsqd and
+// retrying transient errors.
+func (p *ProducerPool) Publish(topic string, body []byte) error {
+	var err error
+	for attempt := 0; attempt <= p.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(p.cfg.RetryDelay * time.Duration(attempt))
+		}
+		err = p.publishOnce(topic, body)
+		if err == nil || !isTransient(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// PublishAsync hands the message to nsqd without waiting for the confirmation.
+// Failed async publishes are retried synchronously in the background.
+// It returns ErrPublishBufferFull instead of blocking once AsyncBuffer publishes are pending.
+func (p *ProducerPool) PublishAsync(topic string, body []byte) error {
+	select {
+	case p.async <- struct{}{}:
+	default:
+		p.rejected.Inc()
+		return ErrPublishBufferFull
+	}
+
+	p.pending.Inc()
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.async
+			p.pending.Dec()
+			p.wg.Done()
+		}()
+
+		err := p.publishAsyncOnce(topic, body)
+		if err == nil {
+			return
+		}
+
+		err = p.Publish(topic, body)
+		if err != nil {
+			log.Warn().Err(err).Str("topic", topic).Msg("Async publish failed")
+		}
+	}()
+	return nil
+}
+
+// Stop waits for pending async publishes and stops all producers.
+func (p *ProducerPool) Stop() {
+	p.wg.Wait()
+	for _, m := range p.members {
+		m.producer.Stop()
+	}
+}
+
+func (p *ProducerPool) publishOnce(topic string, body []byte) error {
+	var lastErr error
+	for _, m := range p.order() {
+		start := time.Now()
+		err := m.producer.Publish(topic, body)
+		p.observe(m, start, err)
+		if err == nil {
+			return nil
+		}
+		if !isTransient(err) {
+			// Another nsqd would reject the message the same way.
+			return err
+		}
+		lastErr = fmt.Errorf("nsqd %s: %w", m.addr, err)
+	}
+	return lastErr
+}
+
+func (p *ProducerPool) publishAsyncOnce(topic string, body []byte) error {
+	m := p.order()[0]
+	done := make(chan *nsq.ProducerTransaction, 1)
+
+	start := time.Now()
+	err := m.producer.PublishAsync(topic, body, done)
+	if err == nil {
+		err = (<-done).Error
+	}
+	p.observe(m, start, err)
+	return err
+}
+
+func (p *ProducerPool) observe(m *poolMember, start time.Time, err error) {
+	p.latency.WithLabelValues(m.addr).Observe(time.Since(start).Seconds())
+	if err == nil {
+		m.markUp()
+		p.up.WithLabelValues(m.addr).Set(1)
+		return
+	}
+
+	p.errors.WithLabelValues(m.addr).Inc()
+	if isTransient(err) {
+		m.markDown(p.cfg.DownFor)
+		p.up.WithLabelValues(m.addr).Set(0)
+		log.Warn().Err(err).Str("nsqd", m.addr).Dur("down-for", p.cfg.DownFor).Msg("Skipping nsqd")
+	}
+}
+
+// order returns the members to try, starting round robin with the available ones.
+// Members marked down come last, so a publish is still tried if all are down.
+func (p *ProducerPool) order() []*poolMember {
+	n := len(p.members)
+	start := int(atomic.AddUint32(&p.next, 1)-1) % n
+	now := time.Now()
+
+	ordered := make([]*poolMember, 0, n)
+	var down []*poolMember
+	for i := 0; i < n; i++ {
+		m := p.members[(start+i)%n]
+		if m.available(now) {
+			ordered = append(ordered, m)
+		} else {
+			down = append(down, m)
+		}
+	}
+	return append(ordered, down...)
+}
+
+// isTransient reports whether publishing again, possibly to another nsqd, may succeed.
+// nsqd rejects invalid topics and messages with E_BAD_* errors.
+func isTransient(err error) bool {
+	var protoErr nsq.ErrProtocol
+	if errors.As(err, &protoErr) {
+		return !strings.HasPrefix(protoErr.Reason, "E_BAD_")
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeProducer stands in for a nsqd connection.
+type fakeProducer struct {
+	mu        sync.Mutex
+	err       error
+	published []string
+}
+
+func (f *fakeProducer) Publish(topic string, body []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.published = append(f.published, string(body))
+	return nil
+}
+
+func (f *fakeProducer) PublishAsync(topic string, body []byte, done chan *nsq.ProducerTransaction, args ...interface{}) error {
+	err := f.Publish(topic, body)
+	go func() { done <- &nsq.ProducerTransaction{Error: err} }()
+	return nil
+}
+
+func (f *fakeProducer) Stop() {}
+
+func (f *fakeProducer) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.published)
+}
+
+func newTestPool(t *testing.T, cfg PoolConfig, producers ...*fakeProducer) *ProducerPool {
+	var members []*poolMember
+	for i, p := range producers {
+		members = append(members, &poolMember{addr: string(rune('a' + i)), producer: p})
+	}
+	pool, err := newProducerPool(members, cfg, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestProducerPoolRoundRobin(t *testing.T) {
+	a, b := &fakeProducer{}, &fakeProducer{}
+	pool := newTestPool(t, DefaultPoolConfig, a, b)
+
+	for i := 0; i < 4; i++ {
+		err := pool.Publish("default", []byte("msg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.count() != 2 || b.count() != 2 {
+		t.Errorf("published a=%d b=%d, want 2 each", a.count(), b.count())
+	}
+}
+
+func TestProducerPoolFailover(t *testing.T) {
+	a, b := &fakeProducer{err: nsq.ErrNotConnected}, &fakeProducer{}
+	pool := newTestPool(t, DefaultPoolConfig, a, b)
+
+	for i := 0; i < 3; i++ {
+		err := pool.Publish("default", []byte("msg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.count() != 3 {
+		t.Errorf("published %d to the healthy nsqd, want 3", b.count())
+	}
+	// a is skipped once it failed.
+	if n := testutil.ToFloat64(pool.errors.WithLabelValues("a")); n != 1 {
+		t.Errorf("errors for a = %v, want 1", n)
+	}
+	if up := testutil.ToFloat64(pool.up.WithLabelValues("a")); up != 0 {
+		t.Errorf("a is still marked up")
+	}
+}
+
+func TestProducerPoolPermanentError(t *testing.T) {
+	a, b := &fakeProducer{err: nsq.ErrProtocol{Reason: "E_BAD_TOPIC"}}, &fakeProducer{}
+	pool := newTestPool(t, PoolConfig{Retries: 3, DownFor: time.Second, AsyncBuffer: 1}, a, b)
+
+	err := pool.Publish("bad topic", []byte("msg"))
+	if !errors.As(err, &nsq.ErrProtocol{}) {
+		t.Errorf("got %v, want the protocol error", err)
+	}
+	if b.count() != 0 {
+		t.Error("permanent error was failed over")
+	}
+}
+
+func TestProducerPoolRetriesWhenAllDown(t *testing.T) {
+	a := &fakeProducer{err: nsq.ErrNotConnected}
+	pool := newTestPool(t, PoolConfig{Retries: 2, AsyncBuffer: 1}, a)
+
+	err := pool.Publish("default", []byte("msg"))
+	if !errors.Is(err, nsq.ErrNotConnected) {
+		t.Errorf("got %v, want ErrNotConnected", err)
+	}
+	if n := testutil.ToFloat64(pool.errors.WithLabelValues("a")); n != 3 {
+		t.Errorf("tried %v times, want 3", n)
+	}
+}
+
+func TestProducerPoolAsync(t *testing.T) {
+	a := &fakeProducer{}
+	pool := newTestPool(t, PoolConfig{AsyncBuffer: 2}, a)
+
+	// Fill the buffer without letting the publishes finish.
+	pool.async <- struct{}{}
+	pool.async <- struct{}{}
+	err := pool.PublishAsync("default", []byte("msg"))
+	if err != ErrPublishBufferFull {
+		t.Errorf("got %v, want ErrPublishBufferFull", err)
+	}
+	<-pool.async
+	<-pool.async
+
+	for i := 0; i < 2; i++ {
+		err = pool.PublishAsync("default", []byte("msg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool.Stop()
+
+	if a.count() != 2 {
+		t.Errorf("published %d, want 2", a.count())
+	}
+	if n := testutil.ToFloat64(pool.pending); n != 0 {
+		t.Errorf("pending = %v after Stop, want 0", n)
+	}
+}
+
+// Pools register their metrics with the registerer they are given.
+func TestNewProducerPoolRegisterer(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		reg := prometheus.NewRegistry()
+		pool, err := NewProducerPool([]string{"nsqd"}, DefaultPoolConfig, reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.Stop()
+		if n := testutil.CollectAndCount(reg, "nsq_producer_up"); n != 1 {
+			t.Errorf("registered %d nsq_producer_up series, want 1", n)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Domain events written to the outbox.
+const (
+	EventUserCreated  = "user.created"
+	EventUserLoggedIn = "user.logged_in"
+	EventUserDeleted  = "user.deleted"
+)
+
+// UserTopic is the NSQ topic and NATS subject all user events are relayed to.
+const UserTopic = "users"
+
+const (
+	outboxInterval   = time.Second
+	outboxBatchSize  = 100
+	outboxMaxBackoff = 5 * time.Minute
+	outboxRetention  = 24 * time.Hour
+)
+
+// Envelope is the wire format of an outbox event as published to NSQ and NATS.
+// The traceparent is kept at the top level so consumers can extract it the
+// same way they do for the plain messages produced by ProduceToNSQPOST.
+type Envelope struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Traceparent string          `json:"traceparent,omitempty"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// UserEvent is the payload of all user.* events.
+type UserEvent struct {
+	UserID string `json:"userid"`
+}
+
+// InsertOutboxEvent stores an event in the outbox as part of tx.
+// The event is only relayed once tx commits.
+func InsertOutboxEvent(ctx context.Context, tx pgx.Tx, topic, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("unable to marshal %s event: %w", eventType, err)
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	sql := `INSERT INTO outbox (topic, event_type, payload, traceparent) VALUES ($1, $2, $3, $4)`
+
+	_, err = tx.Exec(ctx, sql, topic, eventType, payload, carrier.Get("traceparent"))
+	if err != nil {
+		return fmt.Errorf("unable to insert %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// OutboxPublisher publishes a single relayed event to a broker. The id stays the
+// same for every attempt, so brokers and consumers can drop redeliveries.
+type OutboxPublisher func(id, topic string, body []byte) error
+
+// OutboxStore holds the events of the outbox, see PostgresOutbox.
+type OutboxStore interface {
+	// Due locks up to limit unsent events whose next attempt is due and passes them to fn.
+	// The changes fn makes through the OutboxTx are stored if it returns nil.
+	Due(ctx context.Context, limit int, fn func(batch []outboxRow, tx OutboxTx) error) error
+	// Stats returns the number of unsent events and the age of the oldest one in seconds.
+	Stats(ctx context.Context) (pending int64, lag float64, err error)
+	// DeleteSent drops the events sent longer than retention ago.
+	DeleteSent(ctx context.Context, retention time.Duration) error
+}
+
+// OutboxTx updates the events of a batch.
+type OutboxTx interface {
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, retryIn time.Duration) error
+}
+
+// OutboxRelay periodically publishes pending outbox rows and marks them sent.
+// Failed rows are retried with exponential backoff.
+type OutboxRelay struct {
+	store      OutboxStore
+	publishers []OutboxPublisher
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	published prometheus.Counter
+	failures  prometheus.Counter
+	pending   prometheus.Gauge
+	lag       prometheus.Gauge
+}
+
+type outboxRow struct {
+	id          int64
+	topic       string
+	eventType   string
+	payload     []byte
+	traceparent string
+	createdAt   time.Time
+	attempts    int
+}
+
+// NewOutboxRelay creates the relay and registers its metrics. Every event is
+// handed to all publishers; it only counts as sent once all of them succeeded.
+func NewOutboxRelay(store OutboxStore, reg prometheus.Registerer, publishers ...OutboxPublisher) *OutboxRelay {
+	o := &OutboxRelay{
+		store:      store,
+		publishers: publishers,
+	}
+
+	o.published = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "outbox_events_published_total",
+		Help:        "How many outbox events were relayed to the brokers.",
+		ConstLabels: prometheus.Labels{"service": service},
+	})
+	o.failures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "outbox_publish_failures_total",
+		Help:        "How many attempts to relay an outbox event failed.",
+		ConstLabels: prometheus.Labels{"service": service},
+	})
+	o.pending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "outbox_pending_events",
+		Help:        "How many outbox events have not been relayed yet.",
+		ConstLabels: prometheus.Labels{"service": service},
+	})
+	o.lag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "outbox_lag_seconds",
+		Help:        "Age of the oldest outbox event that has not been relayed yet.",
+		ConstLabels: prometheus.Labels{"service": service},
+	})
+	reg.MustRegister(o.published, o.failures, o.pending, o.lag)
+
+	return o
+}
+
+// Start runs the relay in the background until Stop is called.
+func (o *OutboxRelay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		o.run(ctx)
+	}()
+}
+
+// Stop stops the relay and waits for the current batch to finish.
+func (o *OutboxRelay) Stop() {
+	if o.cancel == nil {
+		return
+	}
+	o.cancel()
+	o.wg.Wait()
+}
+
+func (o *OutboxRelay) run(ctx context.Context) {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going without waiting as long as full batches are returned.
+		n, err := o.relayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Caller().Msg("outbox relay")
+		}
+
+		err = o.updateStats(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Caller().Msg("outbox stats")
+		}
+
+		if n == outboxBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes up to outboxBatchSize due events.
+func (o *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	n := 0
+	err := o.store.Due(ctx, outboxBatchSize, func(batch []outboxRow, tx OutboxTx) error {
+		n = len(batch)
+		for _, row := range batch {
+			err := o.publish(row)
+			if err != nil {
+				o.failures.Inc()
+				backoff := outboxBackoff(row.attempts + 1)
+				log.Warn().Err(err).Int64("id", row.id).Str("type", row.eventType).Dur("retry-in", backoff).Msg("outbox publish failed")
+
+				err = tx.MarkFailed(ctx, row.id, err.Error(), backoff)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			o.published.Inc()
+			err = tx.MarkSent(ctx, row.id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (o *OutboxRelay) publish(row outboxRow) error {
+	id := fmt.Sprintf("outbox-%d", row.id)
+	body, err := json.Marshal(Envelope{
+		ID:          id,
+		Type:        row.eventType,
+		OccurredAt:  row.createdAt,
+		Traceparent: row.traceparent,
+		Data:        row.payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, publish := range o.publishers {
+		err := publish(id, row.topic, body)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateStats refreshes the lag metrics and drops relayed events older than outboxRetention.
+func (o *OutboxRelay) updateStats(ctx context.Context) error {
+	pending, lag, err := o.store.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	o.pending.Set(float64(pending))
+	o.lag.Set(lag)
+
+	return o.store.DeleteSent(ctx, outboxRetention)
+}
+
+// PostgresOutbox is the outbox table. Due locks the rows with SKIP LOCKED,
+// so several backend replicas can relay concurrently.
+type PostgresOutbox struct {
+	pg *pgxpool.Pool
+}
+
+func NewPostgresOutbox(pg *pgxpool.Pool) *PostgresOutbox {
+	return &PostgresOutbox{pg: pg}
+}
+
+func (p *PostgresOutbox) Due(ctx context.Context, limit int, fn func(batch []outboxRow, tx OutboxTx) error) error {
+	return pgx.BeginFunc(ctx, p.pg, func(tx pgx.Tx) error {
+		sql := `SELECT id, topic, event_type, payload, traceparent, created_at, attempts
+				FROM outbox
+				WHERE sent_at IS NULL AND next_attempt_at <= now()
+				ORDER BY id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED`
+
+		rows, err := tx.Query(ctx, sql, limit)
+		if err != nil {
+			return err
+		}
+		batch, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (outboxRow, error) {
+			var r outboxRow
+			err := row.Scan(&r.id, &r.topic, &r.eventType, &r.payload, &r.traceparent, &r.createdAt, &r.attempts)
+			return r, err
+		})
+		if err != nil {
+			return err
+		}
+		return fn(batch, postgresOutboxTx{tx})
+	})
+}
+
+func (p *PostgresOutbox) Stats(ctx context.Context) (int64, float64, error) {
+	var pending int64
+	var lag float64
+
+	sql := `SELECT count(*), coalesce(extract(epoch FROM now() - min(created_at)), 0)::float8
+			FROM outbox WHERE sent_at IS NULL`
+
+	err := p.pg.QueryRow(ctx, sql).Scan(&pending, &lag)
+	return pending, lag, err
+}
+
+func (p *PostgresOutbox) DeleteSent(ctx context.Context, retention time.Duration) error {
+	_, err := p.pg.Exec(ctx, `DELETE FROM outbox WHERE sent_at < now() - $1 * interval '1 second'`, retention.Seconds())
+	return err
+}
+
+type postgresOutboxTx struct {
+	tx pgx.Tx
+}
+
+func (t postgresOutboxTx) MarkSent(ctx context.Context, id int64) error {
+	_, err := t.tx.Exec(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = NULL, sent_at = now() WHERE id = $1`, id)
+	return err
+}
+
+func (t postgresOutboxTx) MarkFailed(ctx context.Context, id int64, reason string, retryIn time.Duration) error {
+	_, err := t.tx.Exec(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = now() + $3 * interval '1 millisecond' WHERE id = $1`,
+		id, reason, retryIn.Milliseconds())
+	return err
+}
+
+// outboxBackoff returns how long to wait before retrying an event that failed attempts times.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 16 {
+		return outboxMaxBackoff
+	}
+	backoff := time.Second << (attempts - 1)
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
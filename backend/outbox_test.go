@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{9, 256 * time.Second},
+		{10, outboxMaxBackoff},
+		{100, outboxMaxBackoff},
+	}
+
+	for _, c := range cases {
+		got := outboxBackoff(c.attempts)
+		if got != c.want {
+			t.Errorf("outboxBackoff(%d) = %s, want %s", c.attempts, got, c.want)
+		}
+	}
+}
+
+// The consumers extract the trace context from the top-level traceparent key.
+func TestEnvelopeTraceparent(t *testing.T) {
+	env := Envelope{
+		ID:          "outbox-1",
+		Type:        EventUserCreated,
+		Traceparent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		Data:        json.RawMessage(`{"userid":"test"}`),
+	}
+
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var carrier map[string]any
+	err = json.Unmarshal(body, &carrier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if carrier["traceparent"] != env.Traceparent {
+		t.Errorf("traceparent = %v, want %s", carrier["traceparent"], env.Traceparent)
+	}
+	if carrier["type"] != EventUserCreated {
+		t.Errorf("type = %v, want %s", carrier["type"], EventUserCreated)
+	}
+}
+
+// memoryOutbox keeps the events in memory, changes of a failed batch are dropped.
+type memoryOutbox struct {
+	now    time.Time
+	events map[int64]*memoryEvent
+}
+
+type memoryEvent struct {
+	row       outboxRow
+	sent      bool
+	lastError string
+	nextAt    time.Time
+}
+
+type memoryOutboxTx struct {
+	outbox  *memoryOutbox
+	changes []func()
+}
+
+func (tx *memoryOutboxTx) MarkSent(ctx context.Context, id int64) error {
+	tx.changes = append(tx.changes, func() {
+		e := tx.outbox.events[id]
+		e.row.attempts++
+		e.sent, e.lastError = true, ""
+	})
+	return nil
+}
+
+func (tx *memoryOutboxTx) MarkFailed(ctx context.Context, id int64, reason string, retryIn time.Duration) error {
+	tx.changes = append(tx.changes, func() {
+		e := tx.outbox.events[id]
+		e.row.attempts++
+		e.lastError, e.nextAt = reason, tx.outbox.now.Add(retryIn)
+	})
+	return nil
+}
+
+func (m *memoryOutbox) Due(ctx context.Context, limit int, fn func(batch []outboxRow, tx OutboxTx) error) error {
+	var batch []outboxRow
+	for id := int64(1); id <= int64(len(m.events)) && len(batch) < limit; id++ {
+		e := m.events[id]
+		if !e.sent && !e.nextAt.After(m.now) {
+			batch = append(batch, e.row)
+		}
+	}
+	tx := &memoryOutboxTx{outbox: m}
+	err := fn(batch, tx)
+	if err != nil {
+		return err
+	}
+	for _, change := range tx.changes {
+		change()
+	}
+	return nil
+}
+
+func (m *memoryOutbox) Stats(ctx context.Context) (int64, float64, error) {
+	return 0, 0, nil
+}
+
+func (m *memoryOutbox) DeleteSent(ctx context.Context, retention time.Duration) error {
+	return nil
+}
+
+func TestRelayBatch(t *testing.T) {
+	store := &memoryOutbox{now: time.Now(), events: map[int64]*memoryEvent{}}
+	for id := int64(1); id <= 2; id++ {
+		store.events[id] = &memoryEvent{row: outboxRow{id: id, topic: UserTopic, eventType: EventUserCreated, payload: []byte(`{"userid":"tim"}`)}}
+	}
+
+	// The second publisher fails the first event once, the event is only sent once both succeeded.
+	var ids []string
+	failures := map[string]int{"outbox-1": 1}
+	relay := NewOutboxRelay(store, prometheus.NewRegistry(),
+		func(id, topic string, body []byte) error {
+			return nil
+		},
+		func(id, topic string, body []byte) error {
+			if failures[id] > 0 {
+				failures[id]--
+				return errors.New("nsqd is down")
+			}
+			var env Envelope
+			err := json.Unmarshal(body, &env)
+			if err != nil || env.ID != id || topic != UserTopic {
+				t.Errorf("published %s to %s: %s", id, topic, body)
+			}
+			ids = append(ids, id)
+			return nil
+		},
+	)
+
+	n, err := relay.relayBatch(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("relayBatch = %d, %v", n, err)
+	}
+	failed, sent := store.events[1], store.events[2]
+	if failed.sent || failed.row.attempts != 1 || failed.lastError != "nsqd is down" || !failed.nextAt.Equal(store.now.Add(time.Second)) {
+		t.Errorf("failed event = %+v", failed)
+	}
+	if !sent.sent || sent.row.attempts != 1 {
+		t.Errorf("sent event = %+v", sent)
+	}
+
+	// The failed event is not retried before its backoff passed.
+	n, err = relay.relayBatch(context.Background())
+	if err != nil || n != 0 {
+		t.Fatalf("relayBatch before backoff = %d, %v", n, err)
+	}
+
+	store.now = store.now.Add(time.Second)
+	n, err = relay.relayBatch(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("relayBatch after backoff = %d, %v", n, err)
+	}
+	if !failed.sent || failed.row.attempts != 2 || failed.lastError != "" {
+		t.Errorf("retried event = %+v", failed)
+	}
+	if len(ids) != 2 || ids[0] != "outbox-2" || ids[1] != "outbox-1" {
+		t.Errorf("published ids = %v", ids)
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"messaging"
 	"net/http"
 
 	"github.com/rs/zerolog/log"
@@ -20,7 +22,7 @@ func (server *Server) ValidateSession(next http.Handler) http.Handler {
 			return
 		}
 
-		_, err = server.redis.Get(context.Background(), cookie.Value).Result()
+		userid, err := server.redis.Get(context.Background(), cookie.Value).Result()
 		if err != nil {
 			log.Info().Msgf("Middleware Validate caught csrf-token does not exist %v", err)
 			http.Redirect(w, r, "/login", http.StatusUnauthorized)
@@ -28,10 +30,19 @@ func (server *Server) ValidateSession(next http.Handler) http.Handler {
 		}
 
 		// log.Info().Msgf("Middleware called", cookie.Value)
-		next.ServeHTTP(w, r)
+		ctx := context.WithValue(r.Context(), userContextKey{}, userid)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+type userContextKey struct{}
+
+// UserFromContext returns the user of the session validated by ValidateSession.
+func UserFromContext(ctx context.Context) (string, bool) {
+	userid, ok := ctx.Value(userContextKey{}).(string)
+	return userid, ok && userid != ""
+}
+
 func (server *Server) ProduceToNSQGET(w http.ResponseWriter, r *http.Request) {
 
 	// The iframe is there so that you will NOT be redirected to a new page.
@@ -43,6 +54,12 @@ func (server *Server) ProduceToNSQGET(w http.ResponseWriter, r *http.Request) {
 		<form action="/protected" method="post" target="dummyframe">
 			<input type="submit" name="NSQmessage" value="Produce NSQ Message" />
 		</form>
+		<form action="/protected?async=true" method="post" target="dummyframe">
+			<input type="submit" name="NSQmessage" value="Produce NSQ Message async" />
+		</form>
+		<form action="/protected/delete" method="post">
+			<input type="submit" name="delete" value="Delete my User" />
+		</form>
 		`
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -79,8 +96,17 @@ func (server *Server) ProduceToNSQPOST(w http.ResponseWriter, r *http.Request) {
 	//TODO enable selection of topic and message
 	// message := "default message"
 
-	// err := server.nsq.Publish("default", []byte(message))
-	err = server.nsq.Publish("default", message)
+	// Async publishes to NSQ return before nsqd confirmed the message.
+	// Brokers without async publishes ignore it.
+	if r.URL.Query().Get("async") == "true" {
+		ctx = messaging.WithAsync(ctx)
+	}
+	err = server.messages.Publish(ctx, "default", messaging.NewMessage("", message))
+	if errors.Is(err, ErrPublishBufferFull) {
+		server.SendErrorMessage(w, r, http.StatusServiceUnavailable, err.Error())
+		log.Info().Msgf("Error when producing message %v", err)
+		return
+	}
 	if err != nil {
 		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
 		log.Info().Msgf("Error when producing message %v", err)
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"proto"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// randomBuffer is how many client nums may wait for the stream before POSTs are rejected.
+const randomBuffer = 16
+
+// RandomStream is one Trainer.fullRandom stream opened by /stream/random.
+type RandomStream struct {
+	ID string
+
+	stream proto.Trainer_FullRandomClient
+	in     chan *proto.Nums
+
+	// The sums are updated by the relaying and the forwarding goroutine and read by both.
+	serverSum      atomic.Int64
+	serverMessages atomic.Int64
+	clientSum      atomic.Int64
+	clientMessages atomic.Int64
+}
+
+// RandomEvent is the data of every nums event.
+type RandomEvent struct {
+	A              int32 `json:"a"`
+	B              int32 `json:"b"`
+	C              int32 `json:"c"`
+	ServerSum      int64 `json:"server_sum"`
+	ServerMessages int64 `json:"server_messages"`
+	ClientSum      int64 `json:"client_sum"`
+	ClientMessages int64 `json:"client_messages"`
+}
+
+// RandomStreams keeps the open streams, so that client nums can be posted to them.
+type RandomStreams struct {
+	mu      sync.Mutex
+	streams map[string]*RandomStream
+}
+
+func NewRandomStreams() *RandomStreams {
+	return &RandomStreams{streams: make(map[string]*RandomStream)}
+}
+
+func (rs *RandomStreams) add(stream proto.Trainer_FullRandomClient) *RandomStream {
+	s := &RandomStream{
+		ID:     uuid.NewString(),
+		stream: stream,
+		in:     make(chan *proto.Nums, randomBuffer),
+	}
+	rs.mu.Lock()
+	rs.streams[s.ID] = s
+	rs.mu.Unlock()
+	return s
+}
+
+func (rs *RandomStreams) remove(id string) {
+	rs.mu.Lock()
+	delete(rs.streams, id)
+	rs.mu.Unlock()
+}
+
+func (rs *RandomStreams) get(id string) (*RandomStream, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	s, ok := rs.streams[id]
+	return s, ok
+}
+
+// forward sends the posted nums until ctx ends. It is the only goroutine calling Send.
+func (s *RandomStream) forward(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case nums := <-s.in:
+			err := s.stream.Send(nums)
+			if err != nil {
+				// Recv in the relaying goroutine reports why the stream ended.
+				return
+			}
+			s.clientSum.Add(nums.Sum())
+			s.clientMessages.Add(1)
+		}
+	}
+}
+
+func (s *RandomStream) event(nums *proto.Nums) RandomEvent {
+	return RandomEvent{
+		A:              nums.A,
+		B:              nums.B,
+		C:              nums.C,
+		ServerSum:      s.serverSum.Load(),
+		ServerMessages: s.serverMessages.Load(),
+		ClientSum:      s.clientSum.Load(),
+		ClientMessages: s.clientMessages.Load(),
+	}
+}
+
+// RandomStreamGET opens a Trainer.fullRandom stream and relays the nums of the server as
+// Server-Sent Events. The first event "open" carries the id to POST client nums to.
+// Every "nums" event carries the running sums of both sides. The stream is canceled
+// as soon as the browser disconnects. Events are written as they are received, so a slow
+// browser slows down the server through the flow control of the stream.
+func (server *Server) RandomStreamGET(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		server.SendErrorMessage(w, r, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	stream, err := proto.NewTrainerClient(server.grpc).FullRandom(ctx)
+	if err != nil {
+		server.SendGRPCError(w, r, err)
+		return
+	}
+	s := server.random.add(stream)
+	defer server.random.remove(s.ID)
+	go s.forward(ctx)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	err = writeEvent(w, "open", map[string]string{"id": s.ID})
+	if err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		nums, err := stream.Recv()
+		if err == io.EOF {
+			writeEvent(w, "close", struct{}{})
+			flusher.Flush()
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Caller().Msg("")
+				writeEvent(w, "error", map[string]string{"error": err.Error()})
+				flusher.Flush()
+			}
+			return
+		}
+
+		s.serverSum.Add(nums.Sum())
+		s.serverMessages.Add(1)
+
+		err = writeEvent(w, "nums", s.event(nums))
+		if err != nil {
+			// The browser went away, cancel ends the stream.
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// RandomStreamPOST sends the nums in the body, e.g. {"a": 1, "b": 2, "c": 3}, on the stream
+// opened by RandomStreamGET with the given id. Responds 503 if the stream cannot keep up.
+// The id is a query parameter, so that the path stays the same in the request metrics.
+func (server *Server) RandomStreamPOST(w http.ResponseWriter, r *http.Request) {
+	s, ok := server.random.get(r.URL.Query().Get("id"))
+	if !ok {
+		server.SendErrorMessage(w, r, http.StatusNotFound, "unknown stream")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1024))
+	if err != nil {
+		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	nums := &proto.Nums{}
+	err = protojson.Unmarshal(body, nums)
+	if err != nil {
+		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	select {
+	case s.in <- nums:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		server.SendErrorMessage(w, r, http.StatusServiceUnavailable, "stream is busy")
+	}
+}
+
+func writeEvent(w io.Writer, event string, data any) error {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, bytes)
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent returns the next Server-Sent Event.
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestRandomStream(t *testing.T) {
+	s, trainer := trainingServer(t)
+	ts := httptest.NewServer(s.mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/stream/random")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+
+	event, data := readEvent(t, events)
+	var open struct{ ID string }
+	err = json.Unmarshal([]byte(data), &open)
+	if event != "open" || err != nil || open.ID == "" {
+		t.Fatalf("first event %s %s", event, data)
+	}
+
+	// The fake trainer echoes every client nums.
+	post, err := http.Post(ts.URL+"/stream/random?id="+open.ID, "application/json", strings.NewReader(`{"a": 1, "b": 2, "c": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Body.Close()
+	checkResponseCode(t, http.StatusAccepted, post.StatusCode)
+
+	event, data = readEvent(t, events)
+	var nums RandomEvent
+	err = json.Unmarshal([]byte(data), &nums)
+	if event != "nums" || err != nil {
+		t.Fatalf("got %s %s", event, data)
+	}
+	if nums.A != 1 || nums.ServerSum != 6 || nums.ServerMessages != 1 {
+		t.Errorf("event = %+v", nums)
+	}
+
+	// Disconnecting cancels the stream.
+	resp.Body.Close()
+	select {
+	case <-trainer.randomDone:
+	case <-time.After(time.Second):
+		t.Fatal("stream was not canceled")
+	}
+
+	post, err = http.Post(ts.URL+"/stream/random?id="+open.ID, "application/json", strings.NewReader(`{"a": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Body.Close()
+	checkResponseCode(t, http.StatusNotFound, post.StatusCode)
+}
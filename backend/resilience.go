@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned without calling a target whose circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker, exported as outbound_circuit_state.
+type BreakerState int
+
+const (
+	Closed BreakerState = iota
+	HalfOpen
+	Open
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
+
+// BreakerConfig opens a breaker once at least MinRequests calls within Window failed
+// at FailureRate or more. After OpenFor one probe call is let through, it closes the
+// breaker if it succeeds and opens it again if it fails.
+type BreakerConfig struct {
+	Window      time.Duration
+	MinRequests int
+	FailureRate float64
+	OpenFor     time.Duration
+}
+
+// Breaker is a circuit breaker based on the error rate.
+type Breaker struct {
+	cfg      BreakerConfig
+	onChange func(BreakerState)
+	now      func() time.Time
+
+	mu                 sync.Mutex
+	state              BreakerState
+	windowStart        time.Time
+	requests, failures int
+	openedAt           time.Time
+	probing            bool
+}
+
+func NewBreaker(cfg BreakerConfig, onChange func(BreakerState)) *Breaker {
+	return &Breaker{cfg: cfg, onChange: onChange, now: time.Now}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState has to be called with the lock held.
+func (b *Breaker) setState(s BreakerState) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	b.requests, b.failures = 0, 0
+	b.windowStart = b.now()
+	if s == Open {
+		b.openedAt = b.now()
+	}
+	if b.onChange != nil {
+		b.onChange(s)
+	}
+}
+
+// Allow returns ErrCircuitOpen or a function to report the result of the call.
+func (b *Breaker) Allow() (done func(failed bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.state == Open && now.Sub(b.openedAt) >= b.cfg.OpenFor {
+		b.setState(HalfOpen)
+	}
+
+	switch b.state {
+	case Open:
+		return nil, ErrCircuitOpen
+	case HalfOpen:
+		if b.probing {
+			return nil, ErrCircuitOpen
+		}
+		b.probing = true
+		return b.probeDone, nil
+	}
+
+	if now.Sub(b.windowStart) >= b.cfg.Window {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	return b.done, nil
+}
+
+func (b *Breaker) probeDone(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if failed {
+		b.setState(Open)
+	} else {
+		b.setState(Closed)
+	}
+}
+
+func (b *Breaker) done(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The breaker opened while the call was running.
+	if b.state != Closed {
+		return
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRate {
+		b.setState(Open)
+	}
+}
+
+// TargetConfig configures the calls to one dependency.
+type TargetConfig struct {
+	Name string
+	// Timeout bounds every attempt, the deadline of the incoming request still applies.
+	Timeout time.Duration
+	// Retries are the attempts after the first one, only for idempotent calls.
+	Retries int
+	// The backoff before retry n is random between 0 and Backoff * 2^n, at most MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Breaker    BreakerConfig
+	// Failed decides whether an error counts against the breaker and is retried.
+	// By default every error does, except canceled calls.
+	Failed func(error) bool
+}
+
+// Target makes the calls to a dependency with deadlines, retries and a circuit breaker.
+type Target struct {
+	cfg      TargetConfig
+	breaker  *Breaker
+	outbound *Outbound
+}
+
+func (t *Target) failed(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if t.cfg.Failed == nil {
+		return true
+	}
+	return t.cfg.Failed(err)
+}
+
+// Do calls call with a context bounded by the Timeout. Failed idempotent calls are retried
+// with jittered backoff while the breaker lets them through and ctx has not ended.
+func (t *Target) Do(ctx context.Context, idempotent bool, call func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = t.attempt(ctx, call)
+		if !idempotent || attempt >= t.cfg.Retries || !t.failed(err) || errors.Is(err, ErrCircuitOpen) {
+			return err
+		}
+
+		timer := time.NewTimer(t.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		t.outbound.retries.WithLabelValues(t.cfg.Name).Inc()
+	}
+}
+
+func (t *Target) attempt(ctx context.Context, call func(ctx context.Context) error) error {
+	done, err := t.breaker.Allow()
+	if err != nil {
+		t.outbound.calls.WithLabelValues(t.cfg.Name, "rejected").Inc()
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+	defer cancel()
+	err = call(ctx)
+	t.record(done, err)
+	return err
+}
+
+// record reports the result of a call to the breaker and the metrics.
+func (t *Target) record(done func(failed bool), err error) {
+	failed := t.failed(err)
+	done(failed)
+	if failed {
+		t.outbound.calls.WithLabelValues(t.cfg.Name, "failure").Inc()
+	} else {
+		t.outbound.calls.WithLabelValues(t.cfg.Name, "success").Inc()
+	}
+}
+
+func (t *Target) backoff(attempt int) time.Duration {
+	max := t.cfg.Backoff << attempt
+	if max <= 0 || max > t.cfg.MaxBackoff {
+		max = t.cfg.MaxBackoff
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// grpcFailed counts the errors that indicate a problem of grpcconsumer, not of the request.
+func grpcFailed(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.ResourceExhausted:
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// natsFailed counts the errors that indicate a problem of the NATS connection. Nobody listening
+// on the subject or no reply within the timeout the client chose is the answer to the request.
+func natsFailed(err error) bool {
+	return !errors.Is(err, nats.ErrNoResponders) && !errors.Is(err, nats.ErrTimeout) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// UnaryInterceptor applies the deadline and the breaker to unary calls. Retries are done by
+// the retry policy of the service config, which knows which methods are idempotent.
+func (t *Target) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := t.Do(ctx, false, func(ctx context.Context) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+		if errors.Is(err, ErrCircuitOpen) {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		return err
+	}
+}
+
+// StreamInterceptor rejects new streams while the breaker is open. Streams live as long as
+// their context, so there is no deadline.
+func (t *Target) StreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		done, err := t.breaker.Allow()
+		if err != nil {
+			t.outbound.calls.WithLabelValues(t.cfg.Name, "rejected").Inc()
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		t.record(done, err)
+		return stream, err
+	}
+}
+
+// defaultBreaker opens if half of at least 10 calls within 10s failed and probes after 5s.
+var defaultBreaker = BreakerConfig{
+	Window:      10 * time.Second,
+	MinRequests: 10,
+	FailureRate: 0.5,
+	OpenFor:     5 * time.Second,
+}
+
+// Outbound are the dependencies the handlers call.
+type Outbound struct {
+	GRPC       *Target
+	TracingApp *Target
+	NATS       *Target
+
+	// endpoints counts the gRPC calls per grpcconsumer replica.
+	endpoints *endpointMetrics
+
+	calls   *prometheus.CounterVec
+	retries *prometheus.CounterVec
+	state   *prometheus.GaugeVec
+}
+
+func NewOutbound(reg prometheus.Registerer) *Outbound {
+	o := &Outbound{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "outbound_calls_total",
+			Help:        "Attempts of outbound calls by target and result: success, failure or rejected by the open circuit breaker.",
+			ConstLabels: prometheus.Labels{"service": service},
+		}, []string{"target", "result"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "outbound_retries_total",
+			Help:        "Retries of idempotent outbound calls by target.",
+			ConstLabels: prometheus.Labels{"service": service},
+		}, []string{"target"}),
+		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "outbound_circuit_state",
+			Help:        "State of the circuit breaker by target: 0 closed, 1 half-open, 2 open.",
+			ConstLabels: prometheus.Labels{"service": service},
+		}, []string{"target"}),
+	}
+	reg.MustRegister(o.calls, o.retries, o.state)
+	o.endpoints = newEndpointMetrics(reg)
+
+	o.GRPC = o.Target(TargetConfig{
+		Name:    "grpcconsumer",
+		Timeout: 5 * time.Second,
+		Breaker: defaultBreaker,
+		Failed:  grpcFailed,
+	})
+	o.TracingApp = o.Target(TargetConfig{
+		Name:       "tracingapp",
+		Timeout:    2 * time.Second,
+		Retries:    2,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: time.Second,
+		Breaker:    defaultBreaker,
+	})
+	// The handler sets the deadline of NATS requests, up to maxRequestTimeout.
+	o.NATS = o.Target(TargetConfig{
+		Name:    "nats",
+		Timeout: maxRequestTimeout,
+		Breaker: defaultBreaker,
+		Failed:  natsFailed,
+	})
+	return o
+}
+
+// Target creates a target whose calls are exported in the outbound_* metrics.
+func (o *Outbound) Target(cfg TargetConfig) *Target {
+	state := o.state.WithLabelValues(cfg.Name)
+	state.Set(float64(Closed))
+	return &Target{
+		cfg:      cfg,
+		outbound: o,
+		breaker: NewBreaker(cfg.Breaker, func(s BreakerState) {
+			log.Warn().Str("target", cfg.Name).Stringer("state", s).Msg("Circuit breaker changed")
+			state.Set(float64(s))
+		}),
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(BreakerConfig{Window: time.Minute, MinRequests: 4, FailureRate: 0.5, OpenFor: time.Second}, nil)
+	b.now = func() time.Time { return now }
+
+	call := func(failed bool) error {
+		done, err := b.Allow()
+		if err != nil {
+			return err
+		}
+		done(failed)
+		return nil
+	}
+
+	// 1 of 3 failed, too few requests and too few failures.
+	for _, failed := range []bool{false, true, false} {
+		call(failed)
+	}
+	if b.State() != Closed {
+		t.Fatalf("%v after one failure", b.State())
+	}
+	call(true)
+	if b.State() != Open {
+		t.Fatalf("%v at a failure rate of 50%%", b.State())
+	}
+	if err := call(false); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("open breaker let a call through: %v", err)
+	}
+
+	// After OpenFor a single probe goes through.
+	now = now.Add(time.Second)
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Error("second call while probing")
+	}
+	probe(true)
+	if b.State() != Open {
+		t.Fatalf("%v after failed probe", b.State())
+	}
+
+	now = now.Add(time.Second)
+	if err := call(false); err != nil {
+		t.Fatal(err)
+	}
+	if b.State() != Closed {
+		t.Errorf("%v after successful probe", b.State())
+	}
+}
+
+func TestTargetDo(t *testing.T) {
+	o := NewOutbound(prometheus.NewRegistry())
+	target := o.Target(TargetConfig{
+		Name:       "test",
+		Timeout:    time.Second,
+		Retries:    2,
+		Backoff:    time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+		Breaker:    defaultBreaker,
+	})
+
+	calls := 0
+	flaky := func(ctx context.Context) error {
+		calls++
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Second {
+			t.Errorf("attempt without deadline of the target")
+		}
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	err := target.Do(context.Background(), true, flaky)
+	if err != nil || calls != 3 {
+		t.Errorf("idempotent call: %v after %d attempts", err, calls)
+	}
+	if n := testutil.ToFloat64(o.retries.WithLabelValues("test")); n != 2 {
+		t.Errorf("%v retries", n)
+	}
+
+	calls = 0
+	err = target.Do(context.Background(), false, flaky)
+	if err == nil || calls != 1 {
+		t.Errorf("not idempotent call: %v after %d attempts", err, calls)
+	}
+
+	// The deadline of the incoming request is kept if it is earlier.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = target.Do(ctx, true, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestTargetUnaryInterceptor(t *testing.T) {
+	o := NewOutbound(prometheus.NewRegistry())
+	interceptor := o.GRPC.UnaryInterceptor()
+
+	calls := 0
+	unavailable := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return status.Error(codes.Unavailable, "connection refused")
+	}
+	invalid := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		return status.Error(codes.InvalidArgument, "no user")
+	}
+
+	// Rejected requests do not open the breaker.
+	for i := 0; i < 20; i++ {
+		interceptor(context.Background(), "/proto.Trainer/stats", nil, nil, nil, invalid)
+	}
+	if s := o.GRPC.breaker.State(); s != Closed {
+		t.Fatalf("%v after invalid requests", s)
+	}
+
+	o = NewOutbound(prometheus.NewRegistry())
+	interceptor = o.GRPC.UnaryInterceptor()
+	for i := 0; i < defaultBreaker.MinRequests; i++ {
+		interceptor(context.Background(), "/proto.Trainer/stats", nil, nil, nil, unavailable)
+	}
+	if v := testutil.ToFloat64(o.state.WithLabelValues("grpcconsumer")); v != float64(Open) {
+		t.Fatalf("circuit state = %v", v)
+	}
+
+	calls = 0
+	err := interceptor(context.Background(), "/proto.Trainer/stats", nil, nil, nil, unavailable)
+	if status.Code(err) != codes.Unavailable || calls != 0 {
+		t.Errorf("open breaker: %v after %d calls", err, calls)
+	}
+	if n := testutil.ToFloat64(o.calls.WithLabelValues("grpcconsumer", "rejected")); n != 1 {
+		t.Errorf("%v rejected calls", n)
+	}
+}
+
+// Requests nobody answers in time do not open the breaker, a closed connection does.
+func TestNATSTargetFailed(t *testing.T) {
+	o := NewOutbound(prometheus.NewRegistry())
+	for _, err := range []error{nats.ErrNoResponders, nats.ErrTimeout, context.DeadlineExceeded} {
+		for i := 0; i < defaultBreaker.MinRequests; i++ {
+			o.NATS.Do(context.Background(), false, func(ctx context.Context) error { return err })
+		}
+	}
+	if o.NATS.breaker.State() != Closed {
+		t.Errorf("breaker %v after requests without reply", o.NATS.breaker.State())
+	}
+	if n := testutil.ToFloat64(o.calls.WithLabelValues("nats", "failure")); n != 0 {
+		t.Errorf("%v failures", n)
+	}
+
+	o = NewOutbound(prometheus.NewRegistry())
+	for i := 0; i < defaultBreaker.MinRequests; i++ {
+		o.NATS.Do(context.Background(), false, func(ctx context.Context) error { return nats.ErrConnectionClosed })
+	}
+	if o.NATS.breaker.State() != Open {
+		t.Errorf("breaker %v after connection errors", o.NATS.breaker.State())
+	}
+}
+
+func TestOutboundServiceLabel(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	o := NewOutbound(reg)
+	o.NATS.Do(context.Background(), false, func(ctx context.Context) error { return nil })
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if !strings.HasPrefix(f.GetName(), "outbound_") {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			found := false
+			for _, l := range m.GetLabel() {
+				found = found || l.GetName() == "service" && l.GetValue() == service
+			}
+			if !found {
+				t.Errorf("%s without service label", f.GetName())
+			}
+		}
+	}
+}
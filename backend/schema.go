@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// schema creates the tables of the backend if they do not exist. db.sql only runs
+// on a fresh volume, so tables added later are missing on existing volumes.
+// Keep the statements in step with db.sql.
+const schema = `
+CREATE TABLE IF NOT EXISTS public.users
+(
+    userid text COLLATE pg_catalog."default" NOT NULL,
+    passwd bytea NOT NULL,
+    CONSTRAINT "Users_pkey" PRIMARY KEY (userid)
+)
+
+TABLESPACE pg_default;
+
+CREATE TABLE IF NOT EXISTS public.outbox
+(
+    id bigserial NOT NULL,
+    topic text COLLATE pg_catalog."default" NOT NULL,
+    event_type text COLLATE pg_catalog."default" NOT NULL,
+    payload jsonb NOT NULL,
+    traceparent text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
+    sent_at timestamp with time zone,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text COLLATE pg_catalog."default",
+    CONSTRAINT outbox_pkey PRIMARY KEY (id)
+)
+
+TABLESPACE pg_default;
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx
+    ON public.outbox (next_attempt_at)
+    WHERE sent_at IS NULL;
+`
+
+// EnsureSchema runs schema, which is idempotent.
+func EnsureSchema(ctx context.Context, pg *pgxpool.Pool) error {
+	_, err := pg.Exec(ctx, schema)
+	if err != nil {
+		return fmt.Errorf("unable to create the tables: %v", err)
+	}
+	return nil
+}
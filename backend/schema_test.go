@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// The tables created at startup are the ones of db.sql.
+func TestSchemaMatchesDBSQL(t *testing.T) {
+	data, err := os.ReadFile("../db.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbSQL := strings.Join(strings.Fields(string(data)), " ")
+
+	for _, stmt := range strings.Split(schema, ";") {
+		stmt = strings.Join(strings.Fields(stmt), " ")
+		if stmt != "" && !strings.Contains(dbSQL, stmt) {
+			t.Errorf("statement not in db.sql: %s", stmt)
+		}
+	}
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"messaging"
 	"net/http"
 	"os"
+	"strings"
+	"telemetry"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -15,17 +18,10 @@ import (
 	"github.com/go-redis/redis/v9"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nats-io/nats.go"
-	"github.com/nsqio/go-nsq"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
@@ -51,17 +47,22 @@ func SetupServer() (*Server, error) {
 		log.Warn().Err(err).Caller().Msg("")
 		return nil, err
 	}
+	err = EnsureSchema(context.Background(), pgconn)
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+		return nil, err
+	}
 
 	/************************** NSQ **********************************/
 
-	nsq, err := ConnectNSQ()
+	nsq, err := ConnectNSQ(prometheus.DefaultRegisterer)
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("")
 		return nil, err
 	}
 
 	/************************ TRACING *********************************/
-	tracer, err := SetupTracerProvider()
+	tracer, err := telemetry.NewTracerProvider(context.Background(), telemetry.Config{Service: service, Environment: environment, ID: id})
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("")
 		return nil, err
@@ -77,13 +78,27 @@ func SetupServer() (*Server, error) {
 	}
 	log.Info().Msg("Successfully connected to Nats.io")
 
+	jsCfg, err := LoadJetStreamConfig()
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+		return nil, err
+	}
+	js, err := ConnectJetStream(nc, jsCfg)
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+		return nil, err
+	}
+
 	/************************ GRPC *********************************/
 
-	conn, err := grpc.Dial(GRPC_URL,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
-		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
-	)
+	outbound := NewOutbound(prometheus.DefaultRegisterer)
+
+	creds, err := GRPCCredentials(context.Background())
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+		return nil, err
+	}
+	conn, err := grpc.Dial(GRPC_URL, GRPCDialOptions(creds, outbound)...)
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("")
 		return nil, err
@@ -103,9 +118,35 @@ func SetupServer() (*Server, error) {
 		mux:   mux,
 		tp:    tracer,
 		nats:  nc,
+		js:    js,
 		grpc:  conn,
+
+		random:   NewRandomStreams(),
+		outbound: outbound,
+	}
+
+	/************************ GATEWAY *********************************/
+
+	s.gateway, err = NewGateway(conn)
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+		return nil, err
+	}
+
+	/************************ MESSAGING *********************************/
+
+	s.messages, err = NewMessagePublisher(nsq, nc, js, prometheus.DefaultRegisterer)
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+		return nil, err
 	}
 
+	/************************ OUTBOX *********************************/
+
+	s.outbox = NewOutboxRelay(NewPostgresOutbox(pgconn), prometheus.DefaultRegisterer, func(id, topic string, body []byte) error {
+		return s.messages.Publish(context.Background(), topic, messaging.NewMessage(id, body))
+	})
+
 	// Register our TracerProvider as the global so any imported
 	// instrumentation in the future will default to using it.
 	otel.SetTracerProvider(s.tp)
@@ -151,15 +192,15 @@ func ConnectPostgre() (*pgxpool.Pool, error) {
 	return conn, nil
 }
 
-func ConnectNSQ() (*nsq.Producer, error) {
-	config := nsq.NewConfig()
-	producer, err := nsq.NewProducer(fmt.Sprintf("%s:4150", NSQD), config)
+// ConnectNSQ creates a producer pool over the comma separated nsqd in NSQ_DEMON.
+func ConnectNSQ(reg prometheus.Registerer) (*ProducerPool, error) {
+	pool, err := NewProducerPool(strings.Split(NSQD, ","), DefaultPoolConfig, reg)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to NSQ Demon %v", err)
 	}
 
 	log.Info().Msgf("Successfully connected to NSQDemon")
-	return producer, nil
+	return pool, nil
 }
 
 // CreateRouter creates the router and attaches some default middlewares.
@@ -214,27 +255,3 @@ func ZLogHttpRequest(next http.Handler) http.Handler {
 		next.ServeHTTP(ww, r)
 	})
 }
-
-// SetupTracerProvider creates the base stuff. Stolen from
-// https://dev.to/aurelievache/learning-go-by-examples-part-10-instrument-your-go-app-with-opentelemetry-and-send-traces-to-jaeger-distributed-tracing-1p4a
-func SetupTracerProvider() (*tracesdk.TracerProvider, error) {
-
-	url := fmt.Sprintf("http://%s/api/traces", Jaeger)
-	// Create the Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
-	if err != nil {
-		return nil, err
-	}
-	tp := tracesdk.NewTracerProvider(
-		// Always be sure to batch in production.
-		tracesdk.WithBatcher(exp),
-		// Record information about this application in a Resource.
-		tracesdk.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(service),
-			attribute.String("environment", environment),
-			attribute.Int64("ID", id),
-		)),
-	)
-	return tp, nil
-}
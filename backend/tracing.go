@@ -24,7 +24,7 @@ import (
 func (server *Server) SpecialTracing(w http.ResponseWriter, r *http.Request) {
 	// Use the global TracerProvider
 
-	ctx, span := server.tp.Tracer("CustomTracer").Start(context.Background(), "SpecialTracing")
+	ctx, span := server.tp.Tracer("CustomTracer").Start(r.Context(), "SpecialTracing")
 
 	n := rand.Intn(1000)
 	time.Sleep(time.Millisecond * time.Duration(n))
@@ -35,7 +35,7 @@ func (server *Server) SpecialTracing(w http.ResponseWriter, r *http.Request) {
 	wg := sync.WaitGroup{}
 	wg.Add(2)
 	go SpecialTracingDeeper(ctx, &wg)
-	go CallOtherServer(ctx, &wg)
+	go CallOtherServer(ctx, server.outbound.TracingApp, &wg)
 
 	wg.Wait()
 
@@ -46,26 +46,34 @@ func (server *Server) SpecialTracing(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Hello %q!", yourName)
 }
 
-// CallOtherServer sends a GET request to another server.
+// CallOtherServer sends a GET request to another server, retried by the target.
 // Injects the TracerID with a traceparent Header.
 // Included are two versions to inject the Header.
-func CallOtherServer(ctx context.Context, wg *sync.WaitGroup) error {
+func CallOtherServer(ctx context.Context, target *Target, wg *sync.WaitGroup) error {
 	defer wg.Done()
 
 	url := fmt.Sprintf("http://%s", TracingApp)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		log.Warn().Err(err).Caller().Msg("")
-		return fmt.Errorf("create request error: %v", err)
-	}
-
-	// Approach 1
-	// This adds the traceparent as a header.
-	otelhttptrace.Inject(ctx, req)
-
-	client := http.Client{}
-	_, err = client.Do(req)
+	err := target.Do(ctx, true, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return fmt.Errorf("create request error: %v", err)
+		}
+
+		// Approach 1
+		// This adds the traceparent as a header.
+		otelhttptrace.Inject(ctx, req)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("tracingApp responded %s", resp.Status)
+		}
+		return nil
+	})
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("")
 		return err
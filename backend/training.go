@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"proto"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	trainingTimeout = 5 * time.Second
+	// uploadTimeout is the deadline for reading and forwarding a whole upload.
+	uploadTimeout = 30 * time.Second
+	maxUploadBody = 10 << 20
+	// uploadChunk is the number of iterations sent per Training message.
+	uploadChunk = 100
+)
+
+// TrainingStatsGET returns the training aggregates of the session user as JSON.
+//
+// Query parameters:
+//   - device: only sessions of this device
+//   - since: only sessions started at or after this RFC 3339 time
+func (server *Server) TrainingStatsGET(w http.ResponseWriter, r *http.Request) {
+	userid, ok := UserFromContext(r.Context())
+	if !ok {
+		server.SendErrorMessage(w, r, http.StatusUnauthorized, ErrNoSessionUser.Error())
+		return
+	}
+
+	req := &proto.StatsRequest{User: userid, DeviceId: r.URL.Query().Get("device")}
+	if val := r.URL.Query().Get("since"); val != "" {
+		since, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			server.SendErrorMessage(w, r, http.StatusBadRequest, "since must be a RFC 3339 time")
+			return
+		}
+		req.Since = timestamppb.New(since)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), trainingTimeout)
+	defer cancel()
+
+	stats, err := proto.NewTrainerClient(server.grpc).Stats(ctx, req)
+	if err != nil {
+		server.SendGRPCError(w, r, err)
+		return
+	}
+	server.SendProto(w, r, stats)
+}
+
+// TrainingForceGET returns the force per iteration of one session of the session user.
+func (server *Server) TrainingForceGET(w http.ResponseWriter, r *http.Request) {
+	userid, ok := UserFromContext(r.Context())
+	if !ok {
+		server.SendErrorMessage(w, r, http.StatusUnauthorized, ErrNoSessionUser.Error())
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		server.SendErrorMessage(w, r, http.StatusBadRequest, "session id must be a positive number")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), trainingTimeout)
+	defer cancel()
+
+	series, err := proto.NewTrainerClient(server.grpc).ForceSeries(ctx, &proto.ForceSeriesRequest{SessionId: id, User: userid})
+	if err != nil {
+		server.SendGRPCError(w, r, err)
+		return
+	}
+	server.SendProto(w, r, series)
+}
+
+// TrainingPOST streams an uploaded workout to Trainer.train and returns the Summary as JSON.
+// The body is a JSON array or newline delimited JSON of Iterations, e.g. {"pos": 3, "force": 20, "secs": 1}.
+// Iterations are forwarded while the body is read, so a workout is never held in memory as a whole.
+//
+// Query parameters:
+//   - device: id of the device, required
+//   - type: the DeviceType, e.g. Leg, required
+//   - min_pos, max_pos: the Settings, required
+//   - start, finish: RFC 3339 times, default to when the upload started and finished
+func (server *Server) TrainingPOST(w http.ResponseWriter, r *http.Request) {
+	userid, ok := UserFromContext(r.Context())
+	if !ok {
+		server.SendErrorMessage(w, r, http.StatusUnauthorized, ErrNoSessionUser.Error())
+		return
+	}
+
+	training, err := trainingFromQuery(r)
+	if err != nil {
+		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	training.User = userid
+
+	ctx, cancel := context.WithTimeout(r.Context(), uploadTimeout)
+	defer cancel()
+
+	stream, err := proto.NewTrainerClient(server.grpc).Train(ctx)
+	if err != nil {
+		server.SendGRPCError(w, r, err)
+		return
+	}
+
+	var sendErr error
+	send := func(iterations []*proto.Iteration) error {
+		chunk := protobuf.Clone(training).(*proto.Training)
+		chunk.Iterations = iterations
+		if chunk.Finish == nil {
+			chunk.Finish = timestamppb.Now()
+		}
+		sendErr = stream.Send(chunk)
+		return sendErr
+	}
+
+	var chunk []*proto.Iteration
+	err = decodeIterations(http.MaxBytesReader(w, r.Body, maxUploadBody), func(it *proto.Iteration) error {
+		chunk = append(chunk, it)
+		if len(chunk) < uploadChunk {
+			return nil
+		}
+		err := send(chunk)
+		chunk = nil
+		return err
+	})
+	if err == nil {
+		err = send(chunk)
+	}
+	// A failed Send means the server ended the stream, CloseAndRecv returns why.
+	if err != nil && sendErr == nil {
+		// Abort the stream so that nothing is stored.
+		cancel()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			server.SendErrorMessage(w, r, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summary, err := stream.CloseAndRecv()
+	if err != nil {
+		server.SendGRPCError(w, r, err)
+		return
+	}
+	server.SendProto(w, r, summary)
+}
+
+// trainingFromQuery returns the Training every chunk of an upload starts from.
+func trainingFromQuery(r *http.Request) (*proto.Training, error) {
+	q := r.URL.Query()
+
+	training := &proto.Training{DeviceID: q.Get("device"), Start: timestamppb.Now()}
+	if training.DeviceID == "" {
+		return nil, errors.New("device is required")
+	}
+
+	deviceType, ok := proto.DeviceType_value[q.Get("type")]
+	if !ok {
+		return nil, errors.New("type must be a device type, e.g. Leg")
+	}
+	training.Devicetype = proto.DeviceType(deviceType)
+
+	minPos, err := strconv.ParseInt(q.Get("min_pos"), 10, 32)
+	if err != nil {
+		return nil, errors.New("min_pos must be a number")
+	}
+	maxPos, err := strconv.ParseInt(q.Get("max_pos"), 10, 32)
+	if err != nil {
+		return nil, errors.New("max_pos must be a number")
+	}
+	training.Settings = &proto.Settings{MinPos: int32(minPos), MaxPos: int32(maxPos)}
+
+	for name, ts := range map[string]**timestamppb.Timestamp{"start": &training.Start, "finish": &training.Finish} {
+		val := q.Get(name)
+		if val == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return nil, errors.New(name + " must be a RFC 3339 time")
+		}
+		*ts = timestamppb.New(t)
+	}
+	return training, nil
+}
+
+// decodeIterations calls fn for every Iteration of a JSON array or of newline delimited JSON.
+func decodeIterations(body io.Reader, fn func(*proto.Iteration) error) error {
+	br := bufio.NewReader(body)
+	array := false
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			array = b == '['
+			br.UnreadByte()
+			break
+		}
+	}
+
+	dec := json.NewDecoder(br)
+	if array {
+		_, err := dec.Token()
+		if err != nil {
+			return err
+		}
+	}
+	for n := 0; ; n++ {
+		if array && !dec.More() {
+			_, err := dec.Token()
+			return err
+		}
+
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if !array && err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		it := &proto.Iteration{}
+		err = protojson.Unmarshal(raw, it)
+		if err != nil {
+			return fmt.Errorf("iteration %d: %w", n, err)
+		}
+		err = fn(it)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SendGRPCError maps the status of a failed gRPC call to the closest HTTP status.
+func (server *Server) SendGRPCError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.InvalidArgument:
+		server.SendErrorMessage(w, r, http.StatusBadRequest, st.Message())
+	case codes.NotFound:
+		server.SendErrorMessage(w, r, http.StatusNotFound, st.Message())
+	case codes.ResourceExhausted:
+		server.SendErrorMessage(w, r, http.StatusRequestEntityTooLarge, st.Message())
+	case codes.Unavailable:
+		server.SendErrorMessage(w, r, http.StatusServiceUnavailable, st.Message())
+	case codes.DeadlineExceeded:
+		server.SendErrorMessage(w, r, http.StatusGatewayTimeout, st.Message())
+	default:
+		log.Warn().Err(err).Caller().Msg("")
+		server.SendErrorMessage(w, r, http.StatusBadGateway, st.Message())
+	}
+}
+
+// SendProto writes msg as JSON, including fields with zero values.
+func (server *Server) SendProto(w http.ResponseWriter, r *http.Request, msg protobuf.Message) {
+	bytes, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(msg)
+	if err != nil {
+		server.SendError(w, r)
+		log.Warn().Err(err).Caller().Msg("")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(bytes)
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"proto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeTrainer struct {
+	proto.UnimplementedTrainerServer
+
+	mu sync.Mutex
+	// received holds the Trainings of the last Train stream.
+	received []*proto.Training
+	// randomDone is closed once FullRandom returned.
+	randomDone chan struct{}
+}
+
+// FullRandom echoes every nums of the client.
+func (f *fakeTrainer) FullRandom(stream proto.Trainer_FullRandomServer) error {
+	defer close(f.randomDone)
+	for {
+		nums, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		err = stream.Send(nums)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (f *fakeTrainer) Train(stream proto.Trainer_TrainServer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.received = nil
+	summary := &proto.Summary{}
+	for {
+		training, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(summary)
+		}
+		if err != nil {
+			return err
+		}
+		f.received = append(f.received, training)
+		summary.DeviceID = training.DeviceID
+		for _, it := range training.Iterations {
+			if it.Pos > training.Settings.MaxPos {
+				return status.Error(codes.InvalidArgument, "position out of bounds")
+			}
+			summary.Force += it.Force
+		}
+	}
+}
+
+func (f *fakeTrainer) trainings() []*proto.Training {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.received
+}
+
+func (*fakeTrainer) Stats(ctx context.Context, req *proto.StatsRequest) (*proto.Stats, error) {
+	return &proto.Stats{User: req.User, DeviceId: req.DeviceId, DeviceTypes: []*proto.DeviceTypeStats{
+		{Devicetype: proto.DeviceType_Leg, Sessions: 2, TotalForce: 600, MaxForce: 300},
+	}}, nil
+}
+
+func (*fakeTrainer) ForceSeries(ctx context.Context, req *proto.ForceSeriesRequest) (*proto.ForceSeries, error) {
+	return nil, status.Error(codes.NotFound, "training session not found")
+}
+
+func trainingServer(t *testing.T) (*Server, *fakeTrainer) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	trainer := &fakeTrainer{randomDone: make(chan struct{})}
+	proto.RegisterTrainerServer(srv, trainer)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s := &Server{grpc: conn, mux: chi.NewRouter(), random: NewRandomStreams()}
+	s.mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, "timw")))
+		})
+	})
+	s.mux.Post("/training", s.TrainingPOST)
+	s.mux.Get("/training/stats", s.TrainingStatsGET)
+	s.mux.Get("/training/sessions/{id}/force", s.TrainingForceGET)
+	s.mux.Get("/stream/random", s.RandomStreamGET)
+	s.mux.Post("/stream/random", s.RandomStreamPOST)
+	return s, trainer
+}
+
+func TestTrainingStatsGET(t *testing.T) {
+	s, _ := trainingServer(t)
+
+	req := httptest.NewRequest("GET", "/training/stats?device=device-1", nil)
+	resp := executeRequest(req, s)
+	checkResponseCode(t, http.StatusOK, resp.Code)
+
+	var stats struct {
+		User        string
+		DeviceId    string
+		DeviceTypes []struct {
+			Devicetype string
+			TotalForce string
+		}
+		Weeks []any
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.User != "timw" || stats.DeviceId != "device-1" {
+		t.Errorf("stats for %q %q", stats.User, stats.DeviceId)
+	}
+	if len(stats.DeviceTypes) != 1 || stats.DeviceTypes[0].Devicetype != "Leg" || stats.DeviceTypes[0].TotalForce != "600" {
+		t.Errorf("device types = %+v", stats.DeviceTypes)
+	}
+	if stats.Weeks == nil {
+		t.Error("empty weeks are not emitted")
+	}
+
+	req = httptest.NewRequest("GET", "/training/stats?since=yesterday", nil)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req, s).Code)
+}
+
+func TestTrainingForceGET(t *testing.T) {
+	s, _ := trainingServer(t)
+
+	req := httptest.NewRequest("GET", "/training/sessions/1/force", nil)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req, s).Code)
+
+	req = httptest.NewRequest("GET", "/training/sessions/abc/force", nil)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req, s).Code)
+}
+
+func TestTrainingPOST(t *testing.T) {
+	s, trainer := trainingServer(t)
+	query := "/training?device=device-1&type=Leg&min_pos=0&max_pos=10&start=2023-01-02T10:00:00Z"
+
+	var ndjson strings.Builder
+	for i := 0; i < 250; i++ {
+		fmt.Fprintf(&ndjson, "{\"pos\": %d, \"force\": 2}\n", i%10)
+	}
+	resp := executeRequest(httptest.NewRequest("POST", query, strings.NewReader(ndjson.String())), s)
+	checkResponseCode(t, http.StatusOK, resp.Code)
+
+	var summary struct {
+		DeviceID string
+		Force    int
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.DeviceID != "device-1" || summary.Force != 500 {
+		t.Errorf("summary = %+v", summary)
+	}
+	received := trainer.trainings()
+	if len(received) != 3 {
+		t.Fatalf("sent %d chunks, want 3", len(received))
+	}
+	first := received[0]
+	if first.User != "timw" || first.Devicetype != proto.DeviceType_Leg || first.Start.AsTime().Hour() != 10 {
+		t.Errorf("training = %v", first)
+	}
+
+	body := `[{"pos": 1, "force": 5}, {"pos": 2, "force": 7}]`
+	resp = executeRequest(httptest.NewRequest("POST", query, strings.NewReader(body)), s)
+	checkResponseCode(t, http.StatusOK, resp.Code)
+	received = trainer.trainings()
+	if len(received) != 1 || len(received[0].Iterations) != 2 {
+		t.Errorf("JSON array sent as %v", received)
+	}
+}
+
+func TestTrainingPOSTInvalid(t *testing.T) {
+	s, _ := trainingServer(t)
+	query := "/training?device=device-1&type=Leg&min_pos=0&max_pos=10"
+
+	tests := []struct {
+		name string
+		url  string
+		body string
+		code int
+	}{
+		{"no device", "/training?type=Leg&min_pos=0&max_pos=10", "", http.StatusBadRequest},
+		{"unknown type", "/training?device=d&type=Arm&min_pos=0&max_pos=10", "", http.StatusBadRequest},
+		{"broken JSON", query, `[{"pos": 1}, {"pos"`, http.StatusBadRequest},
+		{"unknown field", query, `{"weight": 1}`, http.StatusBadRequest},
+		{"rejected by trainer", query, `{"pos": 11}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp := executeRequest(httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body)), s)
+		if resp.Code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.name, resp.Code, tt.code)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -74,7 +75,15 @@ func (server *Server) CreateUserPOST(w http.ResponseWriter, r *http.Request) {
 
 	sql := `INSERT INTO users (userid, passwd) VALUES ($1, $2)`
 
-	_, err = server.pg.Exec(context.Background(), sql, joinedUser, hash)
+	// The user and its user.created event are stored in one transaction,
+	// so the event is relayed if and only if the user exists.
+	err = pgx.BeginFunc(r.Context(), server.pg, func(tx pgx.Tx) error {
+		_, err := tx.Exec(r.Context(), sql, joinedUser, hash)
+		if err != nil {
+			return err
+		}
+		return InsertOutboxEvent(r.Context(), tx, UserTopic, EventUserCreated, UserEvent{UserID: joinedUser})
+	})
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("CreateUserPOST")
 		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
@@ -150,6 +159,16 @@ func (server *Server) LoginUserPOST(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The event is stored before the session exists, so a failed insert leaves no session behind.
+	err = pgx.BeginFunc(r.Context(), server.pg, func(tx pgx.Tx) error {
+		return InsertOutboxEvent(r.Context(), tx, UserTopic, EventUserLoggedIn, UserEvent{UserID: joinedUser})
+	})
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("LoginUserGET")
+		server.SendErrorMessage(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	// Create Cookie
 	token, err := uuid.NewRandom()
 	if err != nil {
@@ -157,8 +176,8 @@ func (server *Server) LoginUserPOST(w http.ResponseWriter, r *http.Request) {
 		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	// Add to Redis
-	err = server.redis.Set(context.Background(), token.String(), token.String(), time.Minute*10).Err()
+	// Add to Redis. The session maps the token to the user it belongs to.
+	err = server.redis.Set(context.Background(), token.String(), joinedUser, time.Minute*10).Err()
 	if err != nil {
 		log.Warn().Err(err).Caller().Msg("LoginUserGET")
 		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
@@ -203,3 +222,45 @@ func (server *Server) LogoutUserPOST(w http.ResponseWriter, r *http.Request) {
 
 	http.SetCookie(w, &newCookie)
 }
+
+// DeleteUserPOST deletes the logged in user and ends the session.
+func (server *Server) DeleteUserPOST(w http.ResponseWriter, r *http.Request) {
+
+	userid, ok := UserFromContext(r.Context())
+	if !ok {
+		server.SendErrorMessage(w, r, http.StatusUnauthorized, ErrNoSessionUser.Error())
+		return
+	}
+
+	err := pgx.BeginFunc(r.Context(), server.pg, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(), "DELETE FROM users WHERE userid=$1", userid)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrUnknownUser
+		}
+		return InsertOutboxEvent(r.Context(), tx, UserTopic, EventUserDeleted, UserEvent{UserID: userid})
+	})
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("DeleteUserPOST")
+		server.SendErrorMessage(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cookie, err := r.Cookie("csrftoken")
+	if err == nil {
+		err = server.redis.Del(context.Background(), cookie.Value).Err()
+		if err != nil {
+			log.Warn().Err(err).Caller().Msg("DeleteUserPOST")
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:  "csrftoken",
+		Value: "",
+	})
+
+	log.Info().Msgf("User %s deleted.", userid)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
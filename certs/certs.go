@@ -0,0 +1,200 @@
+// Package certs loads TLS certificates for gRPC, reloads them when their files change
+// and generates certificates for local development.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Reloader keeps a certificate with its key and a CA bundle up to date with their PEM files.
+// The CA verifies the other side: the server of a client, the client certificates of a server.
+type Reloader struct {
+	certFile, keyFile, caFile string
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	roots *x509.CertPool
+	// loaded are the modification times of the loaded files.
+	loaded map[string]time.Time
+}
+
+// NewReloader loads the files. certFile and keyFile are either both set or both empty.
+// Without caFile the system roots verify servers and servers do not ask for client certificates.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("certificate and key have to be set together")
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Reload reads all files. On errors the previous certificates stay in use.
+func (r *Reloader) Reload() error {
+	loaded := make(map[string]time.Time)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		loaded[f] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+
+	var roots *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates in %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.roots, r.loaded = cert, roots, loaded
+	r.mu.Unlock()
+	return nil
+}
+
+// changed reports whether a file was modified since it was loaded.
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			// Replaced files may be missing for a moment.
+			continue
+		}
+		if !info.ModTime().Equal(r.loaded[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch reloads the files whenever one of them changed, it checks every interval
+// until ctx is canceled. Files that cannot be loaded, e.g. because they are only
+// partly written, are retried on the next check.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			err := r.Reload()
+			if err != nil {
+				log.Warn().Err(err).Caller().Msg("")
+				continue
+			}
+			log.Info().Str("cert", r.certFile).Str("ca", r.caFile).Msg("Reloaded certificates")
+		}
+	}
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.roots
+}
+
+// ServerConfig presents the current certificate to every new connection. With a CA,
+// clients have to present a certificate signed by it (mutual TLS).
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, roots := r.current()
+			if cert == nil {
+				return nil, errors.New("no server certificate")
+			}
+			c := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"},
+			}
+			if roots != nil {
+				c.ClientAuth = tls.RequireAndVerifyClientCert
+				c.ClientCAs = roots
+			}
+			return c, nil
+		},
+	}
+}
+
+// ClientConfig verifies servers against the current CA and presents the current certificate
+// if the server asks for one. An empty serverName is taken from the dialed address.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			if cert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+		// The chain is verified by VerifyConnection, the roots of the config cannot be swapped.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			_, roots := r.current()
+			return verifyServer(cs, roots)
+		},
+	}
+}
+
+func verifyServer(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server sent no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       cs.ServerName,
+	})
+	return err
+}
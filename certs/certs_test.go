@@ -0,0 +1,164 @@
+package certs
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// writeCert issues a certificate of ca into dir and returns the paths of the certificate and key.
+func writeCert(t *testing.T, ca *CA, dir, name string, sans ...string) (string, string) {
+	t.Helper()
+
+	cert, key, err := ca.Issue(name, sans, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	for file, data := range map[string][]byte{certFile: cert, keyFile: key} {
+		err = os.WriteFile(file, data, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+// serve starts a health server with mutual TLS and returns its address and
+// a channel with the identities of the calls.
+func serve(t *testing.T, server *Reloader) (string, chan string) {
+	t.Helper()
+
+	identities := make(chan string, 10)
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(server.ServerConfig())),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			id, _ := Identity(ctx)
+			identities <- id
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), identities
+}
+
+func check(addr string, client *Reloader) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(credentials.NewTLS(client.ClientConfig("localhost"))))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := NewCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(dir, "ca.pem")
+	err = os.WriteFile(caFile, ca.PEM(), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCert, serverKey := writeCert(t, ca, dir, "server", "localhost", "127.0.0.1")
+	server, err := NewReloader(serverCert, serverKey, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, identities := serve(t, server)
+
+	clientCert, clientKey := writeCert(t, ca, dir, "client", "spiffe://test/backend", "backend")
+	client, err := NewReloader(clientCert, clientKey, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = check(addr, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := <-identities; id != "spiffe://test/backend" {
+		t.Errorf("identity = %q", id)
+	}
+
+	// Without a client certificate the handshake fails.
+	anonymous, err := NewReloader("", "", caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = check(addr, anonymous)
+	if err == nil {
+		t.Error("server accepted a client without certificate")
+	}
+
+	// A server certificate of another CA is rejected by the client.
+	other, err := NewCA("other CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeCert(t, other, dir, "server", "localhost")
+	err = server.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = check(addr, client)
+	if err == nil {
+		t.Error("client accepted a server of another CA")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := NewCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeCert(t, ca, dir, "server", "localhost")
+	r, err := NewReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := r.current()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	writeCert(t, ca, dir, "server", "localhost")
+	// The modification time has to differ on file systems with a coarse resolution.
+	future := time.Now().Add(time.Second)
+	err = os.Chtimes(certFile, future, future)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		if after, _ := r.current(); after != before {
+			return
+		}
+	}
+	t.Error("certificate was not reloaded")
+}
@@ -0,0 +1,52 @@
+// dev-certs generates a CA and certificates for TLS between backend and grpcconsumer.
+// The certificates are only meant for local development.
+package main
+
+import (
+	"certs"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	out := flag.String("out", "dev-certs", "directory of the generated files")
+	days := flag.Int("days", 365, "validity of the certificates")
+	server := flag.String("server", "grpcconsumer,localhost,127.0.0.1", "comma separated SANs of grpcconsumer")
+	client := flag.String("client", "spiffe://dev/backend,gobackend", "comma separated SANs of the backend, the first is its identity")
+	flag.Parse()
+
+	validFor := time.Duration(*days) * 24 * time.Hour
+	ca, err := certs.NewCA("coding-challenge dev CA", validFor)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	err = os.MkdirAll(*out, 0o755)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	write(*out, "ca.pem", ca.PEM(), 0o644)
+
+	for _, leaf := range []struct{ name, sans string }{{"grpcconsumer", *server}, {"backend", *client}} {
+		cert, key, err := ca.Issue(leaf.name, strings.Split(leaf.sans, ","), validFor)
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+		write(*out, leaf.name+".pem", cert, 0o644)
+		write(*out, leaf.name+"-key.pem", key, 0o600)
+	}
+}
+
+func write(dir, name string, data []byte, perm os.FileMode) {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, data, perm)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	log.Info().Str("file", path).Msg("Written")
+}
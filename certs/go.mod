@@ -0,0 +1,16 @@
+module certs
+
+go 1.19
+
+require (
+	github.com/rs/zerolog v1.29.0
+	google.golang.org/grpc v1.53.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/mattn/go-colorable v0.1.12 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
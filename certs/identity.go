@@ -0,0 +1,30 @@
+package certs
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity is the first URI, e.g. spiffe://dev/backend, or else the first DNS name in the
+// SAN of the verified client certificate of the call. It is false without mutual TLS.
+func Identity(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	leaf := info.State.VerifiedChains[0][0]
+	if len(leaf.URIs) > 0 {
+		return leaf.URIs[0].String(), true
+	}
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames[0], true
+	}
+	return "", false
+}
@@ -12,4 +12,84 @@ CREATE TABLE IF NOT EXISTS public.users
 TABLESPACE pg_default;
 
 ALTER TABLE IF EXISTS public.users
-    OWNER to postgres;
\ No newline at end of file
+    OWNER to postgres;
+
+-- Table: public.outbox
+-- Domain events are written here in the same transaction as the change that
+-- caused them. The backend relays pending rows to NSQ and NATS.
+
+-- DROP TABLE IF EXISTS public.outbox;
+
+CREATE TABLE IF NOT EXISTS public.outbox
+(
+    id bigserial NOT NULL,
+    topic text COLLATE pg_catalog."default" NOT NULL,
+    event_type text COLLATE pg_catalog."default" NOT NULL,
+    payload jsonb NOT NULL,
+    traceparent text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
+    sent_at timestamp with time zone,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text COLLATE pg_catalog."default",
+    CONSTRAINT outbox_pkey PRIMARY KEY (id)
+)
+
+TABLESPACE pg_default;
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx
+    ON public.outbox (next_attempt_at)
+    WHERE sent_at IS NULL;
+
+ALTER TABLE IF EXISTS public.outbox
+    OWNER to postgres;
+
+-- Table: public.training_sessions
+-- One row per Trainer.train stream, written by grpcconsumer.
+
+-- DROP TABLE IF EXISTS public.training_sessions;
+
+CREATE TABLE IF NOT EXISTS public.training_sessions
+(
+    id bigserial NOT NULL,
+    device_id text COLLATE pg_catalog."default" NOT NULL,
+    device_type text COLLATE pg_catalog."default" NOT NULL,
+    userid text COLLATE pg_catalog."default" NOT NULL,
+    started_at timestamp with time zone NOT NULL,
+    finished_at timestamp with time zone NOT NULL,
+    min_pos integer NOT NULL,
+    max_pos integer NOT NULL,
+    total_force bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT training_sessions_pkey PRIMARY KEY (id),
+    CONSTRAINT training_sessions_duration_check CHECK (finished_at >= started_at)
+)
+
+TABLESPACE pg_default;
+
+CREATE INDEX IF NOT EXISTS training_sessions_user_idx
+    ON public.training_sessions (userid, started_at);
+
+ALTER TABLE IF EXISTS public.training_sessions
+    OWNER to postgres;
+
+-- Table: public.training_iterations
+
+-- DROP TABLE IF EXISTS public.training_iterations;
+
+CREATE TABLE IF NOT EXISTS public.training_iterations
+(
+    session_id bigint NOT NULL,
+    seq integer NOT NULL,
+    pos integer NOT NULL,
+    force integer NOT NULL,
+    secs integer NOT NULL,
+    CONSTRAINT training_iterations_pkey PRIMARY KEY (session_id, seq),
+    CONSTRAINT training_iterations_session_fkey FOREIGN KEY (session_id)
+        REFERENCES public.training_sessions (id) ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.training_iterations
+    OWNER to postgres;
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"proto"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var ErrSessionNotFound = errors.New("training session not found")
+
+// StatsFilter selects the sessions to aggregate. Empty fields match every session.
+type StatsFilter struct {
+	User     string
+	DeviceID string
+	Since    time.Time
+}
+
+// statsWhere applies a StatsFilter passed as $1, $2 and $3.
+const statsWhere = `($1 = '' OR s.userid = $1) AND ($2 = '' OR s.device_id = $2) AND s.started_at >= $3`
+
+func (f StatsFilter) args() []any {
+	return []any{f.User, f.DeviceID, f.Since}
+}
+
+// deviceType parses the device_type column, which stores the enum name.
+func deviceType(name string) proto.DeviceType {
+	return proto.DeviceType(proto.DeviceType_value[name])
+}
+
+// DeviceTypeStats sums up the force of all iterations per device type.
+func (p *PostgresStore) DeviceTypeStats(ctx context.Context, f StatsFilter) ([]*proto.DeviceTypeStats, error) {
+	rows, err := p.pg.Query(ctx, `
+		SELECT s.device_type,
+			COUNT(DISTINCT s.id),
+			COUNT(i.seq),
+			COALESCE(SUM(i.force), 0),
+			COALESCE(AVG(i.force), 0)::float8,
+			COALESCE(MAX(i.force), 0)
+		FROM training_sessions s
+		LEFT JOIN training_iterations i ON i.session_id = s.id
+		WHERE `+statsWhere+`
+		GROUP BY s.device_type
+		ORDER BY s.device_type`,
+		f.args()...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []*proto.DeviceTypeStats
+	for rows.Next() {
+		var name string
+		st := &proto.DeviceTypeStats{}
+		err = rows.Scan(&name, &st.Sessions, &st.Iterations, &st.TotalForce, &st.AvgForce, &st.MaxForce)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		st.Devicetype = deviceType(name)
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// WeekStats counts the sessions per week along with a running total.
+func (p *PostgresStore) WeekStats(ctx context.Context, f StatsFilter) ([]*proto.WeekStats, error) {
+	rows, err := p.pg.Query(ctx, `
+		SELECT date_trunc('week', s.started_at) AS week,
+			COUNT(*),
+			SUM(s.total_force)::bigint,
+			SUM(COUNT(*)) OVER (ORDER BY date_trunc('week', s.started_at))::bigint
+		FROM training_sessions s
+		WHERE `+statsWhere+`
+		GROUP BY week
+		ORDER BY week`,
+		f.args()...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var weeks []*proto.WeekStats
+	for rows.Next() {
+		var week time.Time
+		w := &proto.WeekStats{}
+		err = rows.Scan(&week, &w.Sessions, &w.TotalForce, &w.CumulativeSessions)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		w.Week = timestamppb.New(week)
+		weeks = append(weeks, w)
+	}
+	return weeks, rows.Err()
+}
+
+// PersonalRecords ranks all iterations by force per device type. Ties go to the earlier session.
+func (p *PostgresStore) PersonalRecords(ctx context.Context, f StatsFilter) ([]*proto.PersonalRecord, error) {
+	rows, err := p.pg.Query(ctx, `
+		SELECT device_type, force, session_id, started_at
+		FROM (
+			SELECT s.device_type, i.force, s.id AS session_id, s.started_at,
+				ROW_NUMBER() OVER (PARTITION BY s.device_type ORDER BY i.force DESC, s.started_at, i.seq) AS rank
+			FROM training_sessions s
+			JOIN training_iterations i ON i.session_id = s.id
+			WHERE `+statsWhere+`
+		) ranked
+		WHERE rank = 1
+		ORDER BY device_type`,
+		f.args()...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*proto.PersonalRecord
+	for rows.Next() {
+		var name string
+		var achieved time.Time
+		r := &proto.PersonalRecord{}
+		err = rows.Scan(&name, &r.Force, &r.SessionId, &achieved)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.Devicetype = deviceType(name)
+		r.Achieved = timestamppb.New(achieved)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// ForceSeries returns ErrSessionNotFound unless the session belongs to user.
+func (p *PostgresStore) ForceSeries(ctx context.Context, sessionID int64, user string) (*proto.ForceSeries, error) {
+	var name string
+	err := p.pg.QueryRow(ctx,
+		`SELECT device_type FROM training_sessions WHERE id = $1 AND userid = $2`,
+		sessionID, user,
+	).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pg.Query(ctx, `
+		SELECT seq, pos, force, secs,
+			SUM(force) OVER w,
+			AVG(force) OVER (w ROWS BETWEEN 4 PRECEDING AND CURRENT ROW)::float8,
+			force - LAG(force, 1, force) OVER w
+		FROM training_iterations
+		WHERE session_id = $1
+		WINDOW w AS (ORDER BY seq)
+		ORDER BY seq`,
+		sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &proto.ForceSeries{SessionId: sessionID, Devicetype: deviceType(name)}
+	for rows.Next() {
+		pt := &proto.ForcePoint{}
+		err = rows.Scan(&pt.Seq, &pt.Pos, &pt.Force, &pt.Secs, &pt.CumulativeForce, &pt.MovingAvgForce, &pt.DeltaForce)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		series.Points = append(series.Points, pt)
+	}
+	return series, rows.Err()
+}
+
+// Stats returns the aggregates of one user, optionally of a single device.
+func (s *TrainerServer) Stats(ctx context.Context, req *proto.StatsRequest) (*proto.Stats, error) {
+	if req.User == "" {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	err := authorizeUser(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	f := StatsFilter{User: req.User, DeviceID: req.DeviceId}
+	if req.Since != nil {
+		err := req.Since.CheckValid()
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid since: %v", err)
+		}
+		f.Since = req.Since.AsTime()
+	}
+
+	stats := &proto.Stats{User: req.User, DeviceId: req.DeviceId}
+	stats.DeviceTypes, err = s.store.DeviceTypeStats(ctx, f)
+	if err != nil {
+		return nil, storeError(ctx, err)
+	}
+	stats.Weeks, err = s.store.WeekStats(ctx, f)
+	if err != nil {
+		return nil, storeError(ctx, err)
+	}
+	stats.Records, err = s.store.PersonalRecords(ctx, f)
+	if err != nil {
+		return nil, storeError(ctx, err)
+	}
+	return stats, nil
+}
+
+func (s *TrainerServer) ForceSeries(ctx context.Context, req *proto.ForceSeriesRequest) (*proto.ForceSeries, error) {
+	if req.User == "" || req.SessionId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "user and session_id are required")
+	}
+	err := authorizeUser(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	series, err := s.store.ForceSeries(ctx, req.SessionId, req.User)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, storeError(ctx, err)
+	}
+	return series, nil
+}
+
+// storeError hides database errors from clients, unless the call was canceled.
+func storeError(ctx context.Context, err error) error {
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return status.FromContextError(err).Err()
+	}
+	log.Warn().Err(err).Caller(1).Msg("")
+	return status.Error(codes.Unavailable, "training store unavailable")
+}
+
+// StatsCollector exports the aggregates of all users per device type.
+// The store is queried on every scrape.
+type StatsCollector struct {
+	store   TrainingStore
+	timeout time.Duration
+
+	sessions   *prometheus.Desc
+	iterations *prometheus.Desc
+	forceSum   *prometheus.Desc
+	forceAvg   *prometheus.Desc
+	forceMax   *prometheus.Desc
+}
+
+func NewStatsCollector(store TrainingStore) *StatsCollector {
+	labels := []string{"device_type"}
+	return &StatsCollector{
+		store:   store,
+		timeout: 5 * time.Second,
+
+		sessions:   prometheus.NewDesc("training_sessions", "Stored training sessions.", labels, nil),
+		iterations: prometheus.NewDesc("training_iterations", "Stored training iterations.", labels, nil),
+		forceSum:   prometheus.NewDesc("training_force_sum", "Force of all stored iterations.", labels, nil),
+		forceAvg:   prometheus.NewDesc("training_force_avg", "Average force of a stored iteration.", labels, nil),
+		forceMax:   prometheus.NewDesc("training_force_max", "Highest force of a stored iteration.", labels, nil),
+	}
+}
+
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.sessions
+	ch <- c.iterations
+	ch <- c.forceSum
+	ch <- c.forceAvg
+	ch <- c.forceMax
+}
+
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	stats, err := c.store.DeviceTypeStats(ctx, StatsFilter{})
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+		return
+	}
+	for _, st := range stats {
+		label := st.Devicetype.String()
+		ch <- prometheus.MustNewConstMetric(c.sessions, prometheus.GaugeValue, float64(st.Sessions), label)
+		ch <- prometheus.MustNewConstMetric(c.iterations, prometheus.GaugeValue, float64(st.Iterations), label)
+		ch <- prometheus.MustNewConstMetric(c.forceSum, prometheus.GaugeValue, float64(st.TotalForce), label)
+		ch <- prometheus.MustNewConstMetric(c.forceAvg, prometheus.GaugeValue, st.AvgForce, label)
+		ch <- prometheus.MustNewConstMetric(c.forceMax, prometheus.GaugeValue, float64(st.MaxForce), label)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"proto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestStats(t *testing.T) {
+	since := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	store := &memoryStore{
+		deviceTypes: []*proto.DeviceTypeStats{{Devicetype: proto.DeviceType_Leg, Sessions: 2, TotalForce: 600}},
+		weeks:       []*proto.WeekStats{{Week: timestamppb.New(since), Sessions: 2, CumulativeSessions: 2}},
+		records:     []*proto.PersonalRecord{{Devicetype: proto.DeviceType_Leg, Force: 300, SessionId: 7}},
+	}
+	client := dialTrainer(t, store)
+
+	stats, err := client.Stats(context.Background(), &proto.StatsRequest{
+		User:     "timw",
+		DeviceId: "device-1",
+		Since:    timestamppb.New(since),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := StatsFilter{User: "timw", DeviceID: "device-1", Since: since}
+	if store.filter != want {
+		t.Errorf("filter = %+v, want %+v", store.filter, want)
+	}
+	if len(stats.DeviceTypes) != 1 || len(stats.Weeks) != 1 || len(stats.Records) != 1 {
+		t.Errorf("stats = %v", stats)
+	}
+	if stats.Records[0].SessionId != 7 {
+		t.Errorf("record session = %d, want 7", stats.Records[0].SessionId)
+	}
+}
+
+func TestStatsErrors(t *testing.T) {
+	client := dialTrainer(t, &memoryStore{})
+	_, err := client.Stats(context.Background(), &proto.StatsRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing user: got %v, want InvalidArgument", err)
+	}
+
+	client = dialTrainer(t, &memoryStore{err: errors.New("connection refused")})
+	_, err = client.Stats(context.Background(), &proto.StatsRequest{User: "timw"})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("store failure: got %v, want Unavailable", err)
+	}
+}
+
+func TestForceSeries(t *testing.T) {
+	client := dialTrainer(t, &memoryStore{series: map[int64]*proto.ForceSeries{
+		1: {SessionId: 1, Points: []*proto.ForcePoint{{Seq: 0, Force: 10, CumulativeForce: 10}}},
+	}})
+
+	series, err := client.ForceSeries(context.Background(), &proto.ForceSeriesRequest{SessionId: 1, User: "timw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Points) != 1 {
+		t.Errorf("points = %v", series.Points)
+	}
+
+	_, err = client.ForceSeries(context.Background(), &proto.ForceSeriesRequest{SessionId: 1, User: "someone"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("foreign session: got %v, want NotFound", err)
+	}
+	_, err = client.ForceSeries(context.Background(), &proto.ForceSeriesRequest{User: "timw"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing session: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestStatsCollector(t *testing.T) {
+	c := NewStatsCollector(&memoryStore{deviceTypes: []*proto.DeviceTypeStats{
+		{Devicetype: proto.DeviceType_Chest, Sessions: 3, Iterations: 30, TotalForce: 900, AvgForce: 30, MaxForce: 50},
+	}})
+
+	expected := `
+# HELP training_force_max Highest force of a stored iteration.
+# TYPE training_force_max gauge
+training_force_max{device_type="Chest"} 50
+# HELP training_sessions Stored training sessions.
+# TYPE training_sessions gauge
+training_sessions{device_type="Chest"} 3
+`
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected), "training_sessions", "training_force_max")
+	if err != nil {
+		t.Error(err)
+	}
+}
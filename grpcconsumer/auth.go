@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthPolicy is the path of the JSON Policy, without it calls are not authenticated.
+var AuthPolicy = os.Getenv("GRPC_AUTH_POLICY")
+
+// AuthSecret verifies the HS256 bearer tokens the backend issues for the user of a session.
+var AuthSecret = os.Getenv("GRPC_AUTH_SECRET")
+
+// Anonymous is the identity of calls without credentials.
+const Anonymous = "anonymous"
+
+// Policy maps identities to the methods they may call. Identities are user:<name> for
+// bearer tokens, apikey:<name> for API keys and Anonymous.
+type Policy struct {
+	// APIKeys maps the hex SHA-256 of a key to the name of its holder, so the policy
+	// does not contain the keys themselves.
+	APIKeys map[string]string `json:"api_keys"`
+	Rules   []Rule            `json:"rules"`
+}
+
+// Rule allows the identities to call the methods. Both may end with * to match a prefix,
+// e.g. user:* or /proto.Trainer/*.
+type Rule struct {
+	Identities []string `json:"identities"`
+	Methods    []string `json:"methods"`
+}
+
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	err = json.Unmarshal(data, policy)
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Allowed reports whether a rule allows identity to call the full method.
+func (p *Policy) Allowed(identity, method string) bool {
+	for _, r := range p.Rules {
+		if matchAny(r.Identities, identity) && matchAny(r.Methods, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if p == s || strings.HasSuffix(p, "*") && strings.HasPrefix(s, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates calls by the bearer token in the authorization or the key in
+// the x-api-key metadata and authorizes them by the Policy.
+type Authenticator struct {
+	policy *Policy
+	secret []byte
+}
+
+func NewAuthenticator(policy *Policy, secret string) (*Authenticator, error) {
+	if secret == "" {
+		return nil, errors.New("GRPC_AUTH_SECRET not set")
+	}
+	return &Authenticator{policy: policy, secret: []byte(secret)}, nil
+}
+
+func (a *Authenticator) identity(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get("authorization"); len(values) > 0 {
+		if !strings.HasPrefix(values[0], "Bearer ") {
+			return "", status.Error(codes.Unauthenticated, "authorization is not a bearer token")
+		}
+		token := strings.TrimPrefix(values[0], "Bearer ")
+		claims := &jwt.RegisteredClaims{}
+		_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+			return a.secret, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil {
+			return "", status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+		}
+		if claims.Subject == "" || claims.ExpiresAt == nil {
+			return "", status.Error(codes.Unauthenticated, "token without subject or expiry")
+		}
+		return "user:" + claims.Subject, nil
+	}
+
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		hash := sha256.Sum256([]byte(values[0]))
+		name, ok := a.policy.APIKeys[hex.EncodeToString(hash[:])]
+		if !ok {
+			return "", status.Error(codes.Unauthenticated, "unknown API key")
+		}
+		return "apikey:" + name, nil
+	}
+
+	return Anonymous, nil
+}
+
+// authorize returns ctx with the identity of the call, see IdentityFromContext.
+func (a *Authenticator) authorize(ctx context.Context, method string) (context.Context, error) {
+	id, err := a.identity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !a.policy.Allowed(id, method) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", id, method)
+	}
+	return context.WithValue(ctx, identityKey{}, id), nil
+}
+
+func (a *Authenticator) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authenticator) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the identity the Authenticator authenticated the call with.
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey{}).(string)
+	return id, ok
+}
+
+// authorizeUser rejects users acting for another user. API keys may act for
+// every user, as do all calls if they are not authenticated at all.
+func authorizeUser(ctx context.Context, user string) error {
+	id, ok := IdentityFromContext(ctx)
+	if !ok || !strings.HasPrefix(id, "user:") {
+		return nil
+	}
+	if strings.TrimPrefix(id, "user:") != user {
+		return status.Errorf(codes.PermissionDenied, "%s may not access the trainings of %s", id, user)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"proto"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func token(t *testing.T, secret, user string, expires time.Time) string {
+	t.Helper()
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   user,
+		ExpiresAt: jwt.NewNumericDate(expires),
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + signed
+}
+
+func TestAuthenticator(t *testing.T) {
+	// The policy of docker compose, operators use dev-ops-key.
+	policy, err := LoadPolicy("policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(policy, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := token(t, "secret", "timw", time.Now().Add(time.Minute))
+
+	tests := []struct {
+		name   string
+		md     []string
+		method string
+		code   codes.Code
+	}{
+		{"user", []string{"authorization", valid}, "/proto.Trainer/fullRandom", codes.OK},
+		{"expired", []string{"authorization", token(t, "secret", "timw", time.Now().Add(-time.Minute))}, "/proto.Trainer/stats", codes.Unauthenticated},
+		{"other secret", []string{"authorization", token(t, "guessed", "timw", time.Now().Add(time.Minute))}, "/proto.Trainer/stats", codes.Unauthenticated},
+		{"no bearer", []string{"authorization", "Basic dGltdzpwdw=="}, "/proto.Trainer/stats", codes.Unauthenticated},
+		{"api key", []string{"x-api-key", "dev-ops-key"}, "/proto.Trainer/stats", codes.OK},
+		{"api key not allowed", []string{"x-api-key", "dev-ops-key"}, "/proto.Trainer/train", codes.PermissionDenied},
+		{"unknown api key", []string{"x-api-key", "guessed"}, "/tutorial.Greeter/SayHello", codes.Unauthenticated},
+		{"anonymous", nil, "/tutorial.Greeter/SayHello", codes.OK},
+		{"anonymous user data", nil, "/proto.Trainer/stats", codes.PermissionDenied},
+		{"anonymous health", nil, "/grpc.health.v1.Health/Check", codes.OK},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.md...))
+		if _, err := a.authorize(ctx, tt.method); status.Code(err) != tt.code {
+			t.Errorf("%s: got %v, want %v", tt.name, status.Code(err), tt.code)
+		}
+	}
+}
+
+func TestAuthenticatorStream(t *testing.T) {
+	policy := &Policy{Rules: []Rule{{Identities: []string{"user:*"}, Methods: []string{"/proto.Trainer/*"}}}}
+	a, err := NewAuthenticator(policy, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dialTrainer(t, &memoryStore{}, grpc.StreamInterceptor(a.Stream()))
+
+	_, err = train(client)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("anonymous got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", token(t, "secret", "timw", time.Now().Add(time.Minute)))
+	stream, err := client.Train(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.CloseAndRecv()
+	if status.Code(err) == codes.PermissionDenied || status.Code(err) == codes.Unauthenticated {
+		t.Errorf("user got %v", err)
+	}
+}
+
+// Users only get and store their own trainings, API keys act for every user.
+func TestAuthenticatorUser(t *testing.T) {
+	policy, err := LoadPolicy("policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(policy, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dialTrainer(t, &memoryStore{}, grpc.UnaryInterceptor(a.Unary()), grpc.StreamInterceptor(a.Stream()))
+	timw := metadata.AppendToOutgoingContext(context.Background(), "authorization", token(t, "secret", "timw", time.Now().Add(time.Minute)))
+
+	_, err = client.Stats(timw, &proto.StatsRequest{User: "timw"})
+	if err != nil {
+		t.Errorf("own stats: %v", err)
+	}
+	_, err = client.Stats(timw, &proto.StatsRequest{User: "eve"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("stats of another user: got %v, want PermissionDenied", err)
+	}
+	_, err = client.ForceSeries(timw, &proto.ForceSeriesRequest{User: "eve", SessionId: 1})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("force of another user: got %v, want PermissionDenied", err)
+	}
+
+	stream, err := client.Train(timw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Minute)
+	eve := training(start, start.Add(time.Second), &proto.Iteration{Pos: 1, Force: 5})
+	eve.User = "eve"
+	stream.Send(eve)
+	_, err = stream.CloseAndRecv()
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("training of another user: got %v, want PermissionDenied", err)
+	}
+
+	ops := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "dev-ops-key")
+	_, err = client.Stats(ops, &proto.StatsRequest{User: "eve"})
+	if err != nil {
+		t.Errorf("api key: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Check reports whether a dependency is usable.
+type Check struct {
+	Name string
+	// Services are NOT_SERVING while the check fails, "" is the health of the whole server.
+	// A check without services is only logged and exported.
+	Services []string
+	Check    func(ctx context.Context) error
+}
+
+// Readiness runs the checks periodically and sets the status of the services in the
+// grpc.health.v1 server accordingly.
+type Readiness struct {
+	health   *health.Server
+	services []string
+	checks   []Check
+
+	interval time.Duration
+	timeout  time.Duration
+
+	up      *prometheus.GaugeVec
+	serving map[string]bool
+}
+
+func NewReadiness(hs *health.Server, reg prometheus.Registerer, services []string, checks ...Check) *Readiness {
+	r := &Readiness{
+		health:   hs,
+		services: services,
+		checks:   checks,
+		interval: 5 * time.Second,
+		timeout:  2 * time.Second,
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_dependency_up",
+			Help: "Whether the last check of a dependency succeeded.",
+		}, []string{"dependency"}),
+		serving: make(map[string]bool),
+	}
+	reg.MustRegister(r.up)
+	return r
+}
+
+// Run checks every interval until ctx is canceled.
+func (r *Readiness) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx)
+		}
+	}
+}
+
+// Check runs all checks once and updates the health server.
+func (r *Readiness) Check(ctx context.Context) {
+	failing := make(map[string]bool)
+	for _, c := range r.checks {
+		cctx, cancel := context.WithTimeout(ctx, r.timeout)
+		err := c.Check(cctx)
+		cancel()
+
+		if err != nil {
+			log.Warn().Err(err).Str("dependency", c.Name).Msg("Dependency check failed")
+			r.up.WithLabelValues(c.Name).Set(0)
+			for _, s := range c.Services {
+				failing[s] = true
+			}
+			continue
+		}
+		r.up.WithLabelValues(c.Name).Set(1)
+	}
+
+	for _, s := range r.services {
+		serving := !failing[s]
+		if serving {
+			r.health.SetServingStatus(s, healthpb.HealthCheckResponse_SERVING)
+		} else {
+			r.health.SetServingStatus(s, healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+
+		if was, ok := r.serving[s]; !ok || was != serving {
+			log.Info().Str("service", s).Bool("serving", serving).Msg("Health changed")
+		}
+		r.serving[s] = serving
+	}
+}
+
+// dialCheck succeeds if a TCP connection to addr can be opened.
+func dialCheck(addr string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
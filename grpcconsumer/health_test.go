@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestReadiness(t *testing.T) {
+	var pgErr, jaegerErr error
+	hs := health.NewServer()
+	r := NewReadiness(hs, prometheus.NewRegistry(),
+		[]string{"", "proto.Trainer", "tutorial.Greeter"},
+		Check{Name: "postgres", Services: []string{"", "proto.Trainer"}, Check: func(context.Context) error { return pgErr }},
+		Check{Name: "jaeger", Check: func(context.Context) error { return jaegerErr }},
+	)
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Status
+	}
+
+	r.Check(context.Background())
+	if status("") != healthpb.HealthCheckResponse_SERVING || status("proto.Trainer") != healthpb.HealthCheckResponse_SERVING {
+		t.Error("not serving with all dependencies up")
+	}
+
+	pgErr = errors.New("connection refused")
+	r.Check(context.Background())
+	if status("") != healthpb.HealthCheckResponse_NOT_SERVING || status("proto.Trainer") != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Error("serving without postgres")
+	}
+	if status("tutorial.Greeter") != healthpb.HealthCheckResponse_SERVING {
+		t.Error("greeter depends on postgres")
+	}
+	if v := testutil.ToFloat64(r.up.WithLabelValues("postgres")); v != 0 {
+		t.Errorf("postgres up = %v", v)
+	}
+
+	// Jaeger is not required for serving.
+	pgErr, jaegerErr = nil, errors.New("connection refused")
+	r.Check(context.Background())
+	if status("") != healthpb.HealthCheckResponse_SERVING {
+		t.Error("not serving without jaeger")
+	}
+	if v := testutil.ToFloat64(r.up.WithLabelValues("jaeger")); v != 0 {
+		t.Errorf("jaeger up = %v", v)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path"
+	"proto"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// LogPayloads is a comma separated list of full methods, e.g. /proto.Trainer/stats, whose
+// messages are logged. "*" logs the messages of all methods. Sensitive fields are redacted.
+var LogPayloads = os.Getenv("GRPC_LOG_PAYLOADS")
+
+// CallLogger logs every call with its method, peer, code, duration, trace id and sizes.
+type CallLogger struct {
+	logger   zerolog.Logger
+	all      bool
+	payloads map[string]bool
+}
+
+func NewCallLogger(logger zerolog.Logger, payloads string) *CallLogger {
+	c := &CallLogger{logger: logger, payloads: make(map[string]bool)}
+	for _, m := range strings.Split(payloads, ",") {
+		m = strings.TrimSpace(m)
+		if m == "*" {
+			c.all = true
+		} else if m != "" {
+			c.payloads[m] = true
+		}
+	}
+	return c
+}
+
+func (c *CallLogger) logPayloads(method string) bool {
+	return c.all || c.payloads[method]
+}
+
+func (c *CallLogger) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		e := c.event(ctx, info.FullMethod, err, start)
+		e.Int("request_size", size(req))
+		if err == nil {
+			e.Int("response_size", size(resp))
+		}
+		if c.logPayloads(info.FullMethod) {
+			e.RawJSON("request", payload(req))
+			if err == nil {
+				e.RawJSON("response", payload(resp))
+			}
+		}
+		e.Msg("gRPC call")
+		return resp, err
+	}
+}
+
+func (c *CallLogger) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		stream := &loggedStream{ServerStream: ss, logger: c.logger, method: info.FullMethod, payloads: c.logPayloads(info.FullMethod)}
+		err := handler(srv, stream)
+
+		c.event(ss.Context(), info.FullMethod, err, start).
+			Int64("received_messages", stream.received.Load()).
+			Int64("received_size", stream.receivedSize.Load()).
+			Int64("sent_messages", stream.sent.Load()).
+			Int64("sent_size", stream.sentSize.Load()).
+			Msg("gRPC stream")
+		return err
+	}
+}
+
+// event starts the entry of a finished call, its level depends on the code.
+func (c *CallLogger) event(ctx context.Context, method string, err error, start time.Time) *zerolog.Event {
+	code := status.Code(err)
+	var e *zerolog.Event
+	switch code {
+	case codes.OK:
+		e = c.logger.Info()
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented, codes.DeadlineExceeded:
+		e = c.logger.Error().Err(err)
+	default:
+		e = c.logger.Warn().Err(err)
+	}
+
+	e.Str("grpc_service", path.Dir(method)[1:]).
+		Str("grpc_method", path.Base(method)).
+		Str("grpc_code", code.String()).
+		Dur("duration", time.Since(start))
+	if p, ok := peer.FromContext(ctx); ok {
+		e.Str("peer", p.Addr.String())
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		e.Str("trace_id", sc.TraceID().String())
+	}
+	return e
+}
+
+// loggedStream counts the messages of a stream and logs them if payloads are enabled.
+type loggedStream struct {
+	grpc.ServerStream
+	logger   zerolog.Logger
+	method   string
+	payloads bool
+
+	// RecvMsg and SendMsg may be called from different goroutines.
+	received, receivedSize atomic.Int64
+	sent, sentSize         atomic.Int64
+}
+
+func (s *loggedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received.Add(1)
+		s.receivedSize.Add(int64(size(m)))
+		s.log("received", m)
+	}
+	return err
+}
+
+func (s *loggedStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Add(1)
+		s.sentSize.Add(int64(size(m)))
+		s.log("sent", m)
+	}
+	return err
+}
+
+func (s *loggedStream) log(direction string, m interface{}) {
+	if s.payloads {
+		s.logger.Debug().Str("grpc_method", s.method).Str("direction", direction).RawJSON("message", payload(m)).Msg("gRPC message")
+	}
+}
+
+func size(m interface{}) int {
+	if msg, ok := m.(protobuf.Message); ok {
+		return protobuf.Size(msg)
+	}
+	return 0
+}
+
+// payload is the message as JSON with the sensitive fields redacted.
+func payload(m interface{}) []byte {
+	msg, ok := m.(protobuf.Message)
+	if !ok {
+		return []byte("null")
+	}
+	bytes, err := protojson.Marshal(proto.Redact(msg))
+	if err != nil {
+		return []byte("null")
+	}
+	return bytes
+}
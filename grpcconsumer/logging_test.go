@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"proto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func logCall(t *testing.T, payloads string, req any, err error) map[string]any {
+	t.Helper()
+
+	var out bytes.Buffer
+	c := NewCallLogger(zerolog.New(&out), payloads)
+	info := &grpc.UnaryServerInfo{FullMethod: "/tutorial.AddressBook/add"}
+	_, _ = c.Unary()(context.Background(), req, info, func(ctx context.Context, req any) (any, error) {
+		return req, err
+	})
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	return entry
+}
+
+func TestCallLogger(t *testing.T) {
+	person := &proto.Person{Name: "Tim", Email: "tim@example.com"}
+
+	entry := logCall(t, "", person, nil)
+	if entry["level"] != "info" || entry["grpc_service"] != "tutorial.AddressBook" || entry["grpc_method"] != "add" || entry["grpc_code"] != "OK" {
+		t.Errorf("entry = %v", entry)
+	}
+	if entry["request_size"] == nil || entry["response_size"] == nil {
+		t.Errorf("no sizes in %v", entry)
+	}
+	if entry["request"] != nil {
+		t.Error("payload logged without being enabled")
+	}
+
+	entry = logCall(t, "/proto.Trainer/stats, /tutorial.AddressBook/add", person, nil)
+	request, _ := entry["request"].(map[string]any)
+	if request["name"] != "Tim" || request["email"] != proto.Redacted {
+		t.Errorf("request = %v", entry["request"])
+	}
+
+	entry = logCall(t, "*", person, status.Error(codes.InvalidArgument, "no name"))
+	if entry["level"] != "warn" || entry["grpc_code"] != "InvalidArgument" || entry["response"] != nil {
+		t.Errorf("entry = %v", entry)
+	}
+
+	entry = logCall(t, "", person, status.Error(codes.Unavailable, "database is down"))
+	if entry["level"] != "error" {
+		t.Errorf("level = %v", entry["level"])
+	}
+}
+
+func TestCallLoggerStream(t *testing.T) {
+	var out bytes.Buffer
+	client := dialTrainer(t, &memoryStore{}, grpc.StreamInterceptor(NewCallLogger(zerolog.New(&out), "*").Stream()))
+	start := time.Now().Add(-time.Minute)
+	_, err := train(client,
+		training(start, start.Add(10*time.Second), &proto.Iteration{Pos: 1, Force: 100}),
+		training(start, start.Add(30*time.Second), &proto.Iteration{Pos: 5, Force: 300}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stream is logged once it finished, after its messages.
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var entry map[string]any
+	err = json.Unmarshal([]byte(lines[len(lines)-1]), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry["message"] != "gRPC stream" || entry["received_messages"] != 2.0 || entry["sent_messages"] != 1.0 {
+		t.Errorf("entry = %v", entry)
+	}
+	if strings.Count(out.String(), `"gRPC message"`) != 3 {
+		t.Errorf("payloads not logged: %s", out.String())
+	}
+}
@@ -6,26 +6,34 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"proto"
+	"syscall"
+	"telemetry"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 )
 
-var Jaeger = os.Getenv("JAEGER_URL")
+var PgURL = os.Getenv("DATABASE_URL")
+
+// Reflection lets tools like grpcurl list the services.
+var Reflection = os.Getenv("GRPC_REFLECTION") == "true"
 
 const (
 	service     = "GRPC_CONSUMER"
@@ -47,51 +55,27 @@ func (g GrpcServer) SayHello(ctx context.Context, in *proto.HelloRequest) (*prot
 	return &proto.HelloReply{Message: "Hello again " + in.GetName()}, nil
 }
 
-// SetupTracerProvider creates the Jaeger exporter.
-func SetupTracerProvider() (*tracesdk.TracerProvider, error) {
-	url := fmt.Sprintf("http://%s/api/traces", Jaeger)
-	// Create the Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
+// ConnectPostgres only fails on an invalid DATABASE_URL. The pool connects lazily, so the
+// server starts without the database and the Trainer is NOT_SERVING until it is reachable.
+func ConnectPostgres() (*pgxpool.Pool, error) {
+	pg, err := pgxpool.New(context.Background(), PgURL)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("unable to connect to database: %v", err)
 	}
-	tp := tracesdk.NewTracerProvider(
-		// Always be sure to batch in production.
-		tracesdk.WithBatcher(exp),
-		// Record information about this application in a Resource.
-		tracesdk.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(service),
-			attribute.String("environment", environment),
-			attribute.Int64("ID", id),
-		)),
-	)
-	return tp, nil
-}
 
-func promMiddleware() grpc.UnaryServerInterceptor {
-	// this is called once!
-	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		// this is called with every request!
-		log.Info().Msg("Prometheus Middleware")
-		return handler(ctx, req)
+	err = pg.Ping(context.Background())
+	if err != nil {
+		log.Warn().Err(err).Msg("Postgres not reachable yet, the Trainer is not serving")
+		return pg, nil
 	}
-}
-
-func logMiddleware() grpc.UnaryServerInterceptor {
-	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		log.Info().Msg("Logging before")
 
-		// Calls wanted function
-		resp, err = handler(ctx, req)
-
-		log.Info().Msgf("Logging after %s %v", resp, err)
-		return resp, err
-	}
+	log.Info().Msg("Successfully connected to Postgres")
+	return pg, nil
 }
+
 func main() {
 
-	tp, err := SetupTracerProvider()
+	tp, err := telemetry.NewTracerProvider(context.Background(), telemetry.Config{Service: service, Environment: environment, ID: id})
 	if err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
@@ -101,6 +85,12 @@ func main() {
 	// I think this is very important but I dont know why...
 	otel.SetTextMapPropagator(propagation.TraceContext{})
 
+	pg, err := ConnectPostgres()
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	defer pg.Close()
+
 	grpcserver := GrpcServer{}
 
 	lis, err := net.Listen("tcp", ":7777")
@@ -108,22 +98,110 @@ func main() {
 		log.Fatal().Msgf("failed to listen: %v", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	creds, err := ServerCredentials(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	calls := NewCallLogger(log.Logger, LogPayloads)
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		otelgrpc.StreamServerInterceptor(),
+		calls.Stream(),
+		grpc_prometheus.StreamServerInterceptor,
+	}
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		otelgrpc.UnaryServerInterceptor(),
+		calls.Unary(),
+		grpc_prometheus.UnaryServerInterceptor,
+	}
+	if TLSClients != "" {
+		authorizer := NewAuthorizer(TLSClients)
+		streamInterceptors = append(streamInterceptors, authorizer.Stream())
+		unaryInterceptors = append(unaryInterceptors, authorizer.Unary())
+	}
+	if AuthPolicy != "" {
+		policy, err := LoadPolicy(AuthPolicy)
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+		authenticator, err := NewAuthenticator(policy, AuthSecret)
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+		streamInterceptors = append(streamInterceptors, authenticator.Stream())
+		unaryInterceptors = append(unaryInterceptors, authenticator.Unary())
+		log.Info().Str("policy", AuthPolicy).Msg("Calls are authenticated")
+	}
+
 	grpcServer := grpc.NewServer(
-		grpc.StreamInterceptor(
-			otelgrpc.StreamServerInterceptor()),
-		grpc.UnaryInterceptor(
-			grpc_middleware.ChainUnaryServer(
-				otelgrpc.UnaryServerInterceptor(),
-				logMiddleware(),
-				promMiddleware(),
-			),
-		),
+		grpc.Creds(creds),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(streamInterceptors...)),
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)),
 	)
 
 	proto.RegisterGreeterServer(grpcServer, grpcserver)
+	store := NewPostgresStore(pg)
+	proto.RegisterTrainerServer(grpcServer, NewTrainerServer(store))
+	prometheus.MustRegister(NewStatsCollector(store))
+
+	// The Trainer needs Postgres, the Greeter nothing. Without the collector only traces are lost.
+	checks := []Check{{Name: "postgres", Services: []string{proto.Trainer_ServiceDesc.ServiceName}, Check: store.Check}}
+	if addr := telemetry.CollectorAddr(); addr != "" {
+		checks = append(checks, Check{Name: "otel_collector", Check: dialCheck(addr)})
+	}
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	readiness := NewReadiness(healthServer, prometheus.DefaultRegisterer,
+		[]string{"", proto.Trainer_ServiceDesc.ServiceName, proto.Greeter_ServiceDesc.ServiceName},
+		checks...,
+	)
+	readiness.Check(ctx)
+	go readiness.Run(ctx)
+
+	// Export all methods with zero values before they are called the first time.
+	grpc_prometheus.EnableHandlingTimeHistogram()
+	grpc_prometheus.Register(grpcServer)
+
+	if Reflection {
+		reflection.Register(grpcServer)
+		log.Info().Msg("Server reflection enabled")
+	}
 
 	http.Handle("/metrics", promhttp.Handler())
 	go http.ListenAndServe(":2112", nil)
 
-	grpcServer.Serve(lis)
+	go func() {
+		err := grpcServer.Serve(lis)
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	// Clients watching the health stop sending new calls before the server stops.
+	cancel()
+	healthServer.Shutdown()
+
+	// Streams like fullRandom never end on their own.
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		grpcServer.Stop()
+	}
+
+	err = tp.Shutdown(context.Background())
+	if err != nil {
+		log.Warn().Err(err).Caller().Msg("")
+	}
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"proto"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FullRandom sends random nums every randomInterval and sums up the nums of the client.
+// It ends once the client closes its side of the stream or goes away. Send blocks while
+// the client does not keep up, so a slow client slows the stream down.
+func (s *TrainerServer) FullRandom(stream proto.Trainer_FullRandomServer) error {
+	// The client sums are only written by the receiving goroutine and read after done.
+	var clientSum, clientMessages int64
+	done := make(chan error, 1)
+	go func() {
+		for {
+			nums, err := stream.Recv()
+			if err != nil {
+				done <- err
+				return
+			}
+			clientSum += nums.Sum()
+			clientMessages++
+		}
+	}()
+
+	var serverSum, serverMessages int64
+	ticker := time.NewTicker(s.randomInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			log.Info().Int64("client_sum", clientSum).Int64("client_messages", clientMessages).
+				Int64("server_sum", serverSum).Int64("server_messages", serverMessages).Msg("FullRandom finished")
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case <-ticker.C:
+			nums := &proto.Nums{
+				A: rand.Int31n(100),
+				B: rand.Int31n(100),
+				C: rand.Int31n(100),
+			}
+			err := stream.Send(nums)
+			if err != nil {
+				return err
+			}
+			serverSum += nums.Sum()
+			serverMessages++
+		}
+	}
+}
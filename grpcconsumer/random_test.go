@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"io"
+	"proto"
+	"testing"
+)
+
+func TestFullRandom(t *testing.T) {
+	client := dialTrainer(t, &memoryStore{})
+
+	stream, err := client.FullRandom(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&proto.Nums{A: 1, B: 2, C: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		nums, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nums.A < 0 || nums.A >= 100 {
+			t.Errorf("a = %d, want 0..99", nums.A)
+		}
+	}
+
+	// Closing the send side ends the stream.
+	err = stream.CloseSend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if err != io.EOF {
+		t.Errorf("stream ended with %v", err)
+	}
+}
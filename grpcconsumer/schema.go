@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// schema creates the training tables if they do not exist. db.sql only runs
+// on a fresh volume, so tables added later are missing on existing volumes.
+// Keep the statements in step with db.sql.
+const schema = `
+CREATE TABLE IF NOT EXISTS public.training_sessions
+(
+    id bigserial NOT NULL,
+    device_id text COLLATE pg_catalog."default" NOT NULL,
+    device_type text COLLATE pg_catalog."default" NOT NULL,
+    userid text COLLATE pg_catalog."default" NOT NULL,
+    started_at timestamp with time zone NOT NULL,
+    finished_at timestamp with time zone NOT NULL,
+    min_pos integer NOT NULL,
+    max_pos integer NOT NULL,
+    total_force bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT training_sessions_pkey PRIMARY KEY (id),
+    CONSTRAINT training_sessions_duration_check CHECK (finished_at >= started_at)
+)
+
+TABLESPACE pg_default;
+
+CREATE INDEX IF NOT EXISTS training_sessions_user_idx
+    ON public.training_sessions (userid, started_at);
+
+CREATE TABLE IF NOT EXISTS public.training_iterations
+(
+    session_id bigint NOT NULL,
+    seq integer NOT NULL,
+    pos integer NOT NULL,
+    force integer NOT NULL,
+    secs integer NOT NULL,
+    CONSTRAINT training_iterations_pkey PRIMARY KEY (session_id, seq),
+    CONSTRAINT training_iterations_session_fkey FOREIGN KEY (session_id)
+        REFERENCES public.training_sessions (id) ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+`
+
+// Check pings the database. The first time it is reachable the tables are created,
+// so the Trainer only serves once they exist.
+func (p *PostgresStore) Check(ctx context.Context) error {
+	err := p.pg.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if p.migrated.Load() {
+		return nil
+	}
+
+	_, err = p.pg.Exec(ctx, schema)
+	if err != nil {
+		return fmt.Errorf("unable to create the tables: %v", err)
+	}
+	p.migrated.Store(true)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"proto"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Session is one validated Trainer.train stream.
+type Session struct {
+	DeviceID   string
+	DeviceType proto.DeviceType
+	User       string
+	Start      time.Time
+	Finish     time.Time
+	MinPos     int32
+	MaxPos     int32
+	TotalForce int64
+	Iterations []*proto.Iteration
+}
+
+// Duration is the time from the start of the first to the finish of the last Training.
+func (s *Session) Duration() time.Duration {
+	return s.Finish.Sub(s.Start)
+}
+
+// TrainingStore persists finished sessions and aggregates them, see analytics.go.
+type TrainingStore interface {
+	SaveSession(ctx context.Context, s *Session) (int64, error)
+
+	DeviceTypeStats(ctx context.Context, f StatsFilter) ([]*proto.DeviceTypeStats, error)
+	WeekStats(ctx context.Context, f StatsFilter) ([]*proto.WeekStats, error)
+	PersonalRecords(ctx context.Context, f StatsFilter) ([]*proto.PersonalRecord, error)
+	ForceSeries(ctx context.Context, sessionID int64, user string) (*proto.ForceSeries, error)
+}
+
+// PostgresStore writes sessions and their iterations in one transaction.
+type PostgresStore struct {
+	pg *pgxpool.Pool
+	// migrated is set once Check created the tables.
+	migrated atomic.Bool
+}
+
+func NewPostgresStore(pg *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pg: pg}
+}
+
+// SaveSession returns the id of the new session.
+func (p *PostgresStore) SaveSession(ctx context.Context, s *Session) (int64, error) {
+	var id int64
+	err := pgx.BeginFunc(ctx, p.pg, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO training_sessions
+				(device_id, device_type, userid, started_at, finished_at, min_pos, max_pos, total_force)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id`,
+			s.DeviceID, s.DeviceType.String(), s.User, s.Start, s.Finish, s.MinPos, s.MaxPos, s.TotalForce,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]any, len(s.Iterations))
+		for i, it := range s.Iterations {
+			rows[i] = []any{id, i, it.Pos, it.Force, it.Secs}
+		}
+		_, err = tx.CopyFrom(ctx,
+			pgx.Identifier{"training_iterations"},
+			[]string{"session_id", "seq", "pos", "force", "secs"},
+			pgx.CopyFromRows(rows),
+		)
+		return err
+	})
+	return id, err
+}
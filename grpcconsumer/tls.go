@@ -0,0 +1,89 @@
+package main
+
+import (
+	"certs"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Without GRPC_TLS_CERT and GRPC_TLS_KEY the server is plaintext.
+var TLSCert = os.Getenv("GRPC_TLS_CERT")
+var TLSKey = os.Getenv("GRPC_TLS_KEY")
+
+// TLSClientCA enables mutual TLS, clients need a certificate signed by it.
+var TLSClientCA = os.Getenv("GRPC_TLS_CLIENT_CA")
+
+// TLSClients are the comma separated identities, see certs.Identity, allowed to call.
+// Empty allows every client with a valid certificate.
+var TLSClients = os.Getenv("GRPC_TLS_CLIENTS")
+
+// ServerCredentials reloads the certificates every 10s if they changed until ctx is canceled.
+func ServerCredentials(ctx context.Context) (credentials.TransportCredentials, error) {
+	if TLSClients != "" && TLSClientCA == "" {
+		return nil, errors.New("GRPC_TLS_CLIENTS needs GRPC_TLS_CLIENT_CA")
+	}
+	if TLSCert == "" {
+		return insecure.NewCredentials(), nil
+	}
+	r, err := certs.NewReloader(TLSCert, TLSKey, TLSClientCA)
+	if err != nil {
+		return nil, err
+	}
+	go r.Watch(ctx, 10*time.Second)
+	return credentials.NewTLS(r.ServerConfig()), nil
+}
+
+// Authorizer rejects calls of clients whose certificate identity is not allowed.
+type Authorizer struct {
+	allowed map[string]bool
+}
+
+func NewAuthorizer(identities string) *Authorizer {
+	a := &Authorizer{allowed: make(map[string]bool)}
+	for _, id := range strings.Split(identities, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			a.allowed[id] = true
+		}
+	}
+	return a
+}
+
+func (a *Authorizer) authorize(ctx context.Context) error {
+	id, ok := certs.Identity(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "no client certificate")
+	}
+	if !a.allowed[id] {
+		return status.Errorf(codes.PermissionDenied, "client %q is not allowed", id)
+	}
+	return nil
+}
+
+func (a *Authorizer) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		err := a.authorize(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authorizer) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := a.authorize(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// peerContext is the context of a call over mutual TLS by a client with the given URI SAN.
+func peerContext(uri string) context.Context {
+	u, _ := url.Parse(uri)
+	cert := &x509.Certificate{URIs: []*url.URL{u}}
+	info := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+func TestAuthorizer(t *testing.T) {
+	a := NewAuthorizer("spiffe://dev/backend, spiffe://dev/admin")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{"allowed", peerContext("spiffe://dev/backend"), codes.OK},
+		{"other client", peerContext("spiffe://dev/loadtest"), codes.PermissionDenied},
+		{"plaintext", context.Background(), codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		if code := status.Code(a.authorize(tt.ctx)); code != tt.code {
+			t.Errorf("%s: got %v, want %v", tt.name, code, tt.code)
+		}
+	}
+}
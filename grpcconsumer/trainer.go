@@ -0,0 +1,179 @@
+package main
+
+import (
+	"io"
+	"math"
+	"proto"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// MaxIterations bounds how many iterations a session may send, as they are buffered until stored.
+	MaxIterations = 100000
+	maxDeviceID   = 64
+)
+
+// TrainerServer stores every Trainer.train stream as one session.
+type TrainerServer struct {
+	proto.UnimplementedTrainerServer
+
+	store TrainingStore
+	// randomInterval is how often FullRandom sends nums.
+	randomInterval time.Duration
+}
+
+func NewTrainerServer(store TrainingStore) *TrainerServer {
+	return &TrainerServer{store: store, randomInterval: 100 * time.Millisecond}
+}
+
+// Train receives the Trainings of one session. All of them must belong to the
+// same device, user and settings. The session is stored once the client closes
+// the stream and summarized in the reply.
+func (s *TrainerServer) Train(stream proto.Trainer_TrainServer) error {
+	var session *Session
+
+	for {
+		training, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if session == nil {
+			session, err = newSession(training)
+			if err == nil {
+				err = authorizeUser(stream.Context(), session.User)
+			}
+		} else {
+			err = session.add(training)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if session == nil {
+		return status.Error(codes.InvalidArgument, "no training received")
+	}
+
+	ctx := stream.Context()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.String("device.id", session.DeviceID),
+		attribute.String("device.type", session.DeviceType.String()),
+		attribute.Int("training.iterations", len(session.Iterations)),
+	)
+
+	id, err := s.store.SaveSession(ctx, session)
+	if err != nil {
+		return storeError(ctx, err)
+	}
+
+	log.Info().Int64("session", id).Str("device", session.DeviceID).Str("user", session.User).
+		Int64("force", session.TotalForce).Dur("duration", session.Duration()).Msg("Stored training")
+
+	return stream.SendAndClose(session.Summary())
+}
+
+// Summary reports the total force, capped at the int32 range of the message.
+func (s *Session) Summary() *proto.Summary {
+	force := s.TotalForce
+	if force > math.MaxInt32 {
+		force = math.MaxInt32
+	}
+	return &proto.Summary{
+		DeviceID:   s.DeviceID,
+		Devicetype: s.DeviceType,
+		Duration:   durationpb.New(s.Duration()),
+		Force:      int32(force),
+	}
+}
+
+func newSession(t *proto.Training) (*Session, error) {
+	if t.DeviceID == "" || len(t.DeviceID) > maxDeviceID {
+		return nil, status.Errorf(codes.InvalidArgument, "device id must have 1 to %d characters", maxDeviceID)
+	}
+	if t.User == "" {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	if _, ok := proto.DeviceType_name[int32(t.Devicetype)]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown device type %d", t.Devicetype)
+	}
+
+	settings := t.GetSettings()
+	if settings == nil {
+		return nil, status.Error(codes.InvalidArgument, "settings are required")
+	}
+	if settings.MinPos < 0 || settings.MinPos > settings.MaxPos {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid settings: min_pos %d, max_pos %d", settings.MinPos, settings.MaxPos)
+	}
+
+	if t.Start == nil {
+		return nil, status.Error(codes.InvalidArgument, "start is required")
+	}
+	err := t.Start.CheckValid()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid start: %v", err)
+	}
+
+	session := &Session{
+		DeviceID:   t.DeviceID,
+		DeviceType: t.Devicetype,
+		User:       t.User,
+		Start:      t.Start.AsTime(),
+		Finish:     t.Start.AsTime(),
+		MinPos:     settings.MinPos,
+		MaxPos:     settings.MaxPos,
+	}
+	return session, session.add(t)
+}
+
+// add validates t against the session and appends its iterations.
+func (s *Session) add(t *proto.Training) error {
+	if t.DeviceID != s.DeviceID || t.Devicetype != s.DeviceType || t.User != s.User {
+		return status.Error(codes.InvalidArgument, "device and user must not change within a session")
+	}
+	if t.GetSettings().GetMinPos() != s.MinPos || t.GetSettings().GetMaxPos() != s.MaxPos {
+		return status.Error(codes.InvalidArgument, "settings must not change within a session")
+	}
+
+	if t.Finish == nil {
+		return status.Error(codes.InvalidArgument, "finish is required")
+	}
+	err := t.Finish.CheckValid()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid finish: %v", err)
+	}
+	finish := t.Finish.AsTime()
+	if finish.Before(s.Start) {
+		return status.Error(codes.InvalidArgument, "finish is before start")
+	}
+	if finish.After(s.Finish) {
+		s.Finish = finish
+	}
+
+	if len(s.Iterations)+len(t.Iterations) > MaxIterations {
+		return status.Errorf(codes.ResourceExhausted, "more than %d iterations", MaxIterations)
+	}
+	for i, it := range t.Iterations {
+		if it.Pos < s.MinPos || it.Pos > s.MaxPos {
+			return status.Errorf(codes.InvalidArgument, "iteration %d: position %d outside of settings %d..%d", i, it.Pos, s.MinPos, s.MaxPos)
+		}
+		if it.Force < 0 || it.Secs < 0 {
+			return status.Errorf(codes.InvalidArgument, "iteration %d: force and secs must not be negative", i)
+		}
+		s.TotalForce += int64(it.Force)
+	}
+	s.Iterations = append(s.Iterations, t.Iterations...)
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"proto"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type memoryStore struct {
+	mu       sync.Mutex
+	err      error
+	sessions []*Session
+
+	// Canned results of the analytics queries.
+	filter      StatsFilter
+	deviceTypes []*proto.DeviceTypeStats
+	weeks       []*proto.WeekStats
+	records     []*proto.PersonalRecord
+	series      map[int64]*proto.ForceSeries
+}
+
+func (m *memoryStore) SaveSession(ctx context.Context, s *Session) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.sessions = append(m.sessions, s)
+	return int64(len(m.sessions)), nil
+}
+
+func (m *memoryStore) DeviceTypeStats(ctx context.Context, f StatsFilter) ([]*proto.DeviceTypeStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filter = f
+	return m.deviceTypes, m.err
+}
+
+func (m *memoryStore) WeekStats(ctx context.Context, f StatsFilter) ([]*proto.WeekStats, error) {
+	return m.weeks, m.err
+}
+
+func (m *memoryStore) PersonalRecords(ctx context.Context, f StatsFilter) ([]*proto.PersonalRecord, error) {
+	return m.records, m.err
+}
+
+func (m *memoryStore) ForceSeries(ctx context.Context, sessionID int64, user string) (*proto.ForceSeries, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	series, ok := m.series[sessionID]
+	if !ok || user != "timw" {
+		return nil, ErrSessionNotFound
+	}
+	return series, nil
+}
+
+// dialTrainer serves a TrainerServer over an in-memory connection.
+func dialTrainer(t *testing.T, store TrainingStore, opts ...grpc.ServerOption) proto.TrainerClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	proto.RegisterTrainerServer(srv, NewTrainerServer(store))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewTrainerClient(conn)
+}
+
+func training(start, finish time.Time, iterations ...*proto.Iteration) *proto.Training {
+	return &proto.Training{
+		DeviceID:   "device-1",
+		Devicetype: proto.DeviceType_Leg,
+		Start:      timestamppb.New(start),
+		Finish:     timestamppb.New(finish),
+		Settings:   &proto.Settings{MinPos: 1, MaxPos: 10},
+		User:       "timw",
+		Iterations: iterations,
+	}
+}
+
+func train(client proto.TrainerClient, trainings ...*proto.Training) (*proto.Summary, error) {
+	stream, err := client.Train(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trainings {
+		err = stream.Send(t)
+		if err != nil {
+			break
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func TestTrain(t *testing.T) {
+	store := &memoryStore{}
+	client := dialTrainer(t, store)
+	start := time.Now().Add(-time.Minute)
+
+	summary, err := train(client,
+		training(start, start.Add(10*time.Second), &proto.Iteration{Pos: 1, Force: 100}, &proto.Iteration{Pos: 10, Force: 200}),
+		training(start, start.Add(30*time.Second), &proto.Iteration{Pos: 5, Force: 300}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Force != 600 || summary.DeviceID != "device-1" || summary.Devicetype != proto.DeviceType_Leg {
+		t.Errorf("summary = %v", summary)
+	}
+	if d := summary.Duration.AsDuration(); d != 30*time.Second {
+		t.Errorf("duration = %v, want 30s", d)
+	}
+	if len(store.sessions) != 1 || len(store.sessions[0].Iterations) != 3 {
+		t.Errorf("stored %d sessions", len(store.sessions))
+	}
+}
+
+func TestTrainInvalid(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	finish := start.Add(time.Second)
+
+	noDevice := training(start, finish)
+	noDevice.DeviceID = ""
+	noSettings := training(start, finish)
+	noSettings.Settings = nil
+	badSettings := training(start, finish)
+	badSettings.Settings = &proto.Settings{MinPos: 5, MaxPos: 1}
+	unknownType := training(start, finish)
+	unknownType.Devicetype = 42
+	otherDevice := training(start, finish)
+	otherDevice.DeviceID = "device-2"
+
+	tests := []struct {
+		name      string
+		trainings []*proto.Training
+		code      codes.Code
+	}{
+		{"empty stream", nil, codes.InvalidArgument},
+		{"no device id", []*proto.Training{noDevice}, codes.InvalidArgument},
+		{"no settings", []*proto.Training{noSettings}, codes.InvalidArgument},
+		{"min above max", []*proto.Training{badSettings}, codes.InvalidArgument},
+		{"unknown device type", []*proto.Training{unknownType}, codes.InvalidArgument},
+		{"position out of bounds", []*proto.Training{training(start, finish, &proto.Iteration{Pos: 11})}, codes.InvalidArgument},
+		{"negative force", []*proto.Training{training(start, finish, &proto.Iteration{Pos: 1, Force: -1})}, codes.InvalidArgument},
+		{"finish before start", []*proto.Training{training(start, start.Add(-time.Second))}, codes.InvalidArgument},
+		{"device changes", []*proto.Training{training(start, finish), otherDevice}, codes.InvalidArgument},
+	}
+
+	store := &memoryStore{}
+	client := dialTrainer(t, store)
+	for _, tt := range tests {
+		_, err := train(client, tt.trainings...)
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.code)
+		}
+	}
+	if len(store.sessions) != 0 {
+		t.Errorf("stored %d invalid sessions", len(store.sessions))
+	}
+}
+
+func TestTrainStoreFailure(t *testing.T) {
+	client := dialTrainer(t, &memoryStore{err: errors.New("connection refused")})
+	start := time.Now()
+
+	_, err := train(client, training(start, start))
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want Unavailable", err)
+	}
+}
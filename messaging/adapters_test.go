@@ -0,0 +1,295 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nsqio/go-nsq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fakeProducer struct {
+	bodies [][]byte
+	async  int
+}
+
+func (f *fakeProducer) Publish(topic string, body []byte) error {
+	f.bodies = append(f.bodies, body)
+	return nil
+}
+
+func (f *fakeProducer) PublishAsync(topic string, body []byte) error {
+	f.async++
+	return f.Publish(topic, body)
+}
+
+func TestNSQHeaderRoundTrip(t *testing.T) {
+	producer := &fakeProducer{}
+	p := NewNSQPublisher(producer)
+
+	msg := &Message{ID: "outbox-1", Header: Header{"traceparent": "00-abc"}, Body: []byte(`{"type":"user.created"}`)}
+	err := p.Publish(context.Background(), "users", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Consumers decoding into a struct still see their fields.
+	var event struct{ Type string }
+	json.Unmarshal(producer.bodies[0], &event)
+	if event.Type != "user.created" {
+		t.Errorf("body %s lost its fields", producer.bodies[0])
+	}
+
+	got := decodeNSQ(producer.bodies[0])
+	if got.ID != "outbox-1" || !reflect.DeepEqual(got.Header, Header{"traceparent": "00-abc"}) {
+		t.Errorf("decoded id %q header %v", got.ID, got.Header)
+	}
+	if string(got.Body) != `{"type":"user.created"}` {
+		t.Errorf("decoded body %s", got.Body)
+	}
+}
+
+func TestNSQPlainBody(t *testing.T) {
+	producer := &fakeProducer{}
+	p := NewNSQPublisher(producer)
+
+	msg := &Message{ID: "1", Header: Header{"traceparent": "00-abc"}, Body: []byte("default message")}
+	p.Publish(context.Background(), "default", msg)
+	if string(producer.bodies[0]) != "default message" {
+		t.Errorf("plain body changed to %s", producer.bodies[0])
+	}
+	if got := decodeNSQ(producer.bodies[0]); string(got.Body) != "default message" {
+		t.Errorf("decoded body %s", got.Body)
+	}
+}
+
+func TestNSQAsync(t *testing.T) {
+	producer := &fakeProducer{}
+	p := NewNSQPublisher(producer)
+
+	p.Publish(context.Background(), "default", NewMessage("", []byte("sync")))
+	p.Publish(WithAsync(context.Background()), "default", NewMessage("", []byte("async")))
+	if len(producer.bodies) != 2 || producer.async != 1 {
+		t.Errorf("published %d messages, %d async, want 2 and 1", len(producer.bodies), producer.async)
+	}
+}
+
+// fakeDelegate records how a message was responded to.
+type fakeDelegate struct {
+	requeued bool
+	delay    time.Duration
+	backoff  bool
+}
+
+func (d *fakeDelegate) OnFinish(*nsq.Message) {}
+func (d *fakeDelegate) OnTouch(*nsq.Message)  {}
+func (d *fakeDelegate) OnRequeue(m *nsq.Message, delay time.Duration, backoff bool) {
+	d.requeued, d.delay, d.backoff = true, delay, backoff
+}
+
+func TestNSQHandler(t *testing.T) {
+	var got *Message
+	h := nsqHandler("users", "audit", time.Minute, func(ctx context.Context, msg *Message) error {
+		got = msg
+		return RetryAfter(errors.New("failed"), 4*time.Second)
+	})
+
+	m := nsq.NewMessage(nsq.MessageID{'1'}, encodeNSQ(&Message{ID: "outbox-1", Body: []byte(`{}`)}))
+	d := &fakeDelegate{}
+	m.Delegate = d
+	m.Attempts = 3
+	m.Timestamp = time.Now().UnixNano()
+
+	if h(m) == nil {
+		t.Error("error of the handler was swallowed")
+	}
+	if got.ID != "outbox-1" || got.Topic != "users" || got.Group != "audit" || got.Attempts != 3 || got.Timestamp.IsZero() {
+		t.Errorf("received %+v", got)
+	}
+	if !d.requeued || d.delay != 4*time.Second || d.backoff {
+		t.Errorf("requeued %t after %s with backoff %t, want requeue after 4s without backoff", d.requeued, d.delay, d.backoff)
+	}
+}
+
+func runNATS(t *testing.T) *nats.Conn {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats-server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+func TestNATS(t *testing.T) {
+	nc := runNATS(t)
+	n := NewNATS(nc)
+
+	received := make(chan *Message, 1)
+	err := n.Subscribe("users", "audit", func(ctx context.Context, msg *Message) error {
+		received <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc.Flush()
+
+	msg := &Message{ID: "1", Header: Header{"traceparent": "00-abc"}, Body: []byte("hello")}
+	err = n.Publish(context.Background(), "users", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-received:
+		if got.ID != "1" || got.Header.Get("traceparent") != "00-abc" || string(got.Body) != "hello" || got.Topic != "users" {
+			t.Errorf("received %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing received")
+	}
+	n.Close()
+}
+
+func TestNATSRespond(t *testing.T) {
+	nc := runNATS(t)
+	n := NewNATS(nc)
+	defer n.Close()
+
+	err := n.Subscribe("ping", "", func(ctx context.Context, msg *Message) error {
+		return msg.Respond([]byte("pong"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := nc.Request("ping", nil, time.Second)
+	if err != nil || string(reply.Data) != "pong" {
+		t.Fatalf("request = %v, %v, want pong", reply, err)
+	}
+
+	if err := NewMessage("1", nil).Respond(nil); err != ErrNoReply {
+		t.Errorf("Respond without request = %v, want ErrNoReply", err)
+	}
+}
+
+// Consumers extract the trace context from the NATS header with propagation.HeaderCarrier.
+func TestNATSTracePropagation(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	nc := runNATS(t)
+	n := NewNATS(nc)
+
+	raw, err := nc.SubscribeSync("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan trace.SpanContext, 1)
+	err = WrapSubscriber(n, HandlerTracing()).Subscribe("users", "audit", func(ctx context.Context, msg *Message) error {
+		handled <- trace.SpanContextFromContext(ctx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc.Flush()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	err = WrapPublisher(n, PublishTracing()).Publish(ctx, "users", NewMessage("1", []byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := raw.NextMsg(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(m.Header)))
+	if !sc.IsValid() || sc.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("extracted %v from header %v", sc, m.Header)
+	}
+
+	select {
+	case sc := <-handled:
+		if sc.TraceID() != span.SpanContext().TraceID() {
+			t.Errorf("handler span in trace %s, want %s", sc.TraceID(), span.SpanContext().TraceID())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing handled")
+	}
+	n.Close()
+}
+
+func TestJetStreamRedelivers(t *testing.T) {
+	nc := runNATS(t)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{Name: "USERS", Subjects: []string{"users"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := NewJetStream(nc, js)
+
+	attempts := make(chan int, 2)
+	err = n.Subscribe("users", "audit", func(ctx context.Context, msg *Message) error {
+		attempts <- msg.Attempts
+		if msg.Attempts == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Publish(context.Background(), "users", NewMessage("1", []byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same id is dropped by the stream.
+	err = n.Publish(context.Background(), "users", NewMessage("1", []byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-attempts:
+			if got != want {
+				t.Errorf("attempt %d, want %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delivery %d missing", want)
+		}
+	}
+	n.Close()
+}
@@ -0,0 +1,48 @@
+package messaging
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EnvInt reads an integer from the environment, falling back to def if unset.
+func EnvInt(key string, def int) int {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return def
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("%s is not a number", key)
+	}
+	return i
+}
+
+// EnvDuration reads a duration like "1s" from the environment, falling back to def if unset.
+func EnvDuration(key string, def time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("%s is not a duration", key)
+	}
+	return d
+}
+
+// EnvBool reads a boolean like "true" from the environment, falling back to def if unset.
+func EnvBool(key string, def bool) bool {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("%s is not a boolean", key)
+	}
+	return b
+}
@@ -0,0 +1,140 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	claimProcessing = "processing"
+	claimDone       = "done"
+)
+
+// ErrInProgress means another member of the same group is still working on the message.
+var ErrInProgress = errors.New("message is processed by another consumer")
+
+// ClaimState is the outcome of trying to claim a message id.
+type ClaimState int
+
+const (
+	Claimed ClaimState = iota
+	InProgress
+	Duplicate
+)
+
+// Deduplicator records processed message ids in Redis, so messages that are delivered
+// or published more than once are only processed once per topic and group.
+type Deduplicator struct {
+	rdb    *redis.Client
+	prefix string
+
+	// claimTTL bounds how long a crashed consumer blocks redeliveries.
+	claimTTL time.Duration
+	// doneTTL is how long a processed id is remembered.
+	doneTTL time.Duration
+
+	duplicates prometheus.Counter
+}
+
+// NewDeduplicator registers messaging_duplicate_messages_total for broker, topic and group
+// with reg, so it may only be called once per combination and registry.
+func NewDeduplicator(rdb *redis.Client, reg prometheus.Registerer, broker, topic, group string, claimTTL, doneTTL time.Duration) *Deduplicator {
+	d := &Deduplicator{
+		rdb:      rdb,
+		prefix:   fmt.Sprintf("processed:%s:%s:%s:", broker, topic, group),
+		claimTTL: claimTTL,
+		doneTTL:  doneTTL,
+	}
+
+	d.duplicates = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "messaging_duplicate_messages_total",
+		Help:        "How many already processed messages were delivered again and skipped.",
+		ConstLabels: prometheus.Labels{"broker": broker, "topic": topic, "group": group},
+	})
+	reg.MustRegister(d.duplicates)
+
+	return d
+}
+
+// Claim tries to take ownership of id with SETNX.
+func (d *Deduplicator) Claim(ctx context.Context, id string) (ClaimState, error) {
+	ok, err := d.rdb.SetNX(ctx, d.prefix+id, claimProcessing, d.claimTTL).Result()
+	if err != nil {
+		return Claimed, err
+	}
+	if ok {
+		return Claimed, nil
+	}
+
+	state, err := d.rdb.Get(ctx, d.prefix+id).Result()
+	if err == redis.Nil {
+		// The claim expired in between, try again next time.
+		return InProgress, nil
+	}
+	if err != nil {
+		return Claimed, err
+	}
+	if state == claimDone {
+		d.duplicates.Inc()
+		return Duplicate, nil
+	}
+	return InProgress, nil
+}
+
+// Done marks id as processed.
+func (d *Deduplicator) Done(ctx context.Context, id string) error {
+	return d.rdb.Set(ctx, d.prefix+id, claimDone, d.doneTTL).Err()
+}
+
+// Release gives up the claim on id so a redelivery can process it.
+func (d *Deduplicator) Release(ctx context.Context, id string) error {
+	return d.rdb.Del(ctx, d.prefix+id).Err()
+}
+
+// Process runs handle unless id was processed before. If handle fails, the claim is
+// released so a redelivery is processed again.
+func (d *Deduplicator) Process(ctx context.Context, id string, handle func() error) error {
+	state, err := d.Claim(ctx, id)
+	if err != nil {
+		return err
+	}
+	switch state {
+	case Duplicate:
+		log.Info().Str("id", id).Msg("Skipping duplicate message")
+		return nil
+	case InProgress:
+		return ErrInProgress
+	}
+
+	err = handle()
+	if err != nil {
+		// The handler's context may be done already.
+		relErr := d.Release(context.Background(), id)
+		if relErr != nil {
+			log.Warn().Err(relErr).Str("id", id).Msg("Unable to release claim")
+		}
+		return err
+	}
+	return d.Done(context.Background(), id)
+}
+
+// Middleware processes every message id once, see Process. Messages without id are always handled.
+func (d *Deduplicator) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			if msg.ID == "" {
+				return next(ctx, msg)
+			}
+			return d.Process(ctx, msg.ID, func() error {
+				return next(ctx, msg)
+			})
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v9"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newDeduplicator(t *testing.T) (*Deduplicator, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewDeduplicator(rdb, prometheus.NewRegistry(), "nsq", "users", "audit", time.Minute, time.Hour), mr
+}
+
+func TestDeduplicatorProcess(t *testing.T) {
+	d, mr := newDeduplicator(t)
+	ctx := context.Background()
+
+	calls := 0
+	handle := func() error {
+		calls++
+		return nil
+	}
+
+	err := d.Process(ctx, "outbox-1", handle)
+	if err != nil || calls != 1 {
+		t.Fatalf("first delivery: %v, %d calls", err, calls)
+	}
+	if v, _ := mr.Get("processed:nsq:users:audit:outbox-1"); v != claimDone {
+		t.Errorf("state = %q", v)
+	}
+
+	err = d.Process(ctx, "outbox-1", handle)
+	if err != nil || calls != 1 {
+		t.Errorf("redelivery: %v, %d calls", err, calls)
+	}
+	if v := testutil.ToFloat64(d.duplicates); v != 1 {
+		t.Errorf("duplicates = %v", v)
+	}
+
+	// A failed message is released and handled again.
+	failure := errors.New("handler failed")
+	err = d.Process(ctx, "outbox-2", func() error { return failure })
+	if err != failure {
+		t.Errorf("failed handler: %v", err)
+	}
+	err = d.Process(ctx, "outbox-2", handle)
+	if err != nil || calls != 2 {
+		t.Errorf("after failure: %v, %d calls", err, calls)
+	}
+}
+
+func TestDeduplicatorInProgress(t *testing.T) {
+	d, mr := newDeduplicator(t)
+	ctx := context.Background()
+
+	state, err := d.Claim(ctx, "outbox-1")
+	if err != nil || state != Claimed {
+		t.Fatalf("claim = %v, %v", state, err)
+	}
+	err = d.Process(ctx, "outbox-1", func() error {
+		t.Error("handled while claimed")
+		return nil
+	})
+	if !errors.Is(err, ErrInProgress) {
+		t.Errorf("err = %v", err)
+	}
+
+	// A crashed consumer blocks the message only until its claim expires.
+	mr.FastForward(time.Minute)
+	state, err = d.Claim(ctx, "outbox-1")
+	if err != nil || state != Claimed {
+		t.Errorf("claim after ttl = %v, %v", state, err)
+	}
+}
+
+func TestDeduplicatorMiddleware(t *testing.T) {
+	d, _ := newDeduplicator(t)
+	m := NewMemory()
+
+	calls := 0
+	WrapSubscriber(m, d.Middleware()).Subscribe("users", "audit", func(ctx context.Context, msg *Message) error {
+		calls++
+		return nil
+	})
+	ctx := context.Background()
+	m.Publish(ctx, "users", NewMessage("outbox-1", nil))
+	m.Publish(ctx, "users", NewMessage("outbox-1", nil))
+	if calls != 1 {
+		t.Errorf("handled %d times, want 1", calls)
+	}
+
+	// Without id there is nothing to compare.
+	m.Publish(ctx, "users", NewMessage("", nil))
+	m.Publish(ctx, "users", NewMessage("", nil))
+	if calls != 3 {
+		t.Errorf("messages without id handled %d times, want 2", calls-1)
+	}
+}
@@ -0,0 +1,169 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// PullConfig configures the durable pull consumers of JetStream subscriptions.
+type PullConfig struct {
+	// Stream is the stream to consume, by default the one holding the topic.
+	Stream string
+	// MaxDeliver is how often a message is delivered before JetStream gives up on it.
+	// Zero delivers forever.
+	MaxDeliver int
+	// AckWait is how long JetStream waits for an ack before redelivering.
+	// Messages another consumer is processing are checked again after AckWait too.
+	AckWait time.Duration
+	// NakDelay is how long a failed message waits before it is redelivered,
+	// unless the handler asked for another delay with RetryAfter.
+	NakDelay  time.Duration
+	BatchSize int
+	FetchWait time.Duration
+	// RetryWait is how long to wait before subscribing again, e.g. until the stream exists.
+	RetryWait time.Duration
+}
+
+// DefaultPullConfig is the PullConfig of a new JetStream adapter.
+var DefaultPullConfig = PullConfig{
+	AckWait:   30 * time.Second,
+	BatchSize: 10,
+	FetchWait: 5 * time.Second,
+	RetryWait: 5 * time.Second,
+}
+
+// pull fetches the messages of the consumer named group in the background
+// until n is closed. It waits until the stream exists, as the publisher creates it.
+func (n *NATS) pull(topic, group string, h Handler) {
+	handle := n.handle(group, h)
+
+	n.pulling.Add(1)
+	go func() {
+		defer n.pulling.Done()
+
+		sub, err := n.pullSubscribe(topic, group)
+		if err != nil {
+			return
+		}
+		n.track(sub)
+
+		for {
+			ctx, cancel := context.WithTimeout(n.ctx, n.Pull.FetchWait)
+			msgs, err := sub.Fetch(n.Pull.BatchSize, nats.Context(ctx))
+			cancel()
+			if n.ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+				continue
+			}
+			if errors.Is(err, nats.ErrBadSubscription) || errors.Is(err, nats.ErrConnectionClosed) ||
+				errors.Is(err, nats.ErrConnectionDraining) {
+				return
+			}
+			if err != nil {
+				log.Warn().Err(err).Str("topic", topic).Str("group", group).Msg("Fetch failed")
+				n.wait(time.Second)
+				continue
+			}
+
+			// A fetched batch is handled completely, even if n is closed meanwhile.
+			for _, m := range msgs {
+				handle(m)
+			}
+		}
+	}()
+}
+
+// pullSubscribe creates or updates the durable consumer and binds to it. As
+// it is not created by nats.go, draining the subscription does not delete it.
+func (n *NATS) pullSubscribe(topic, group string) (*nats.Subscription, error) {
+	for {
+		if n.nc.IsClosed() || n.nc.IsDraining() {
+			return nil, nats.ErrConnectionClosed
+		}
+
+		sub, err := n.bindConsumer(topic, group)
+		if err == nil {
+			return sub, nil
+		}
+		log.Warn().Err(err).Str("stream", n.Pull.Stream).Str("topic", topic).Str("group", group).Msg("Unable to subscribe, retrying")
+		if !n.wait(n.Pull.RetryWait) {
+			return nil, ErrClosed
+		}
+	}
+}
+
+func (n *NATS) bindConsumer(topic, group string) (*nats.Subscription, error) {
+	stream := n.Pull.Stream
+	if stream == "" {
+		var err error
+		stream, err = n.js.StreamNameBySubject(topic)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &nats.ConsumerConfig{
+		Durable:       group,
+		FilterSubject: topic,
+		AckPolicy:     nats.AckExplicitPolicy,
+		MaxDeliver:    n.Pull.MaxDeliver,
+		AckWait:       n.Pull.AckWait,
+	}
+	_, err := n.js.AddConsumer(stream, cfg)
+	var apiErr *nats.APIError
+	if errors.As(err, &apiErr) &&
+		(apiErr.ErrorCode == nats.JSErrCodeConsumerNameExists || apiErr.ErrorCode == nats.JSErrCodeConsumerAlreadyExists) {
+		_, err = n.js.UpdateConsumer(stream, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n.js.PullSubscribe(topic, group, nats.Bind(stream, group), nats.ManualAck())
+}
+
+// wait sleeps for d and reports whether n is still open.
+func (n *NATS) wait(d time.Duration) bool {
+	select {
+	case <-n.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// ack acks handled messages and naks failed ones, delayed as PullConfig says.
+func (n *NATS) ack(m *nats.Msg, err error) {
+	if err == nil {
+		err = m.Ack()
+		if err != nil {
+			log.Warn().Err(err).Str("topic", m.Subject).Msg("Ack failed")
+		}
+		return
+	}
+
+	delay, ok := RetryDelay(err)
+	switch {
+	case errors.Is(err, ErrInProgress):
+		// Another consumer holds the claim, check again once it should be done.
+		delay = n.Pull.AckWait
+	case !ok:
+		delay = n.Pull.NakDelay
+	}
+	log.Warn().Err(err).Str("topic", m.Subject).Int("max-deliver", n.Pull.MaxDeliver).Dur("delay", delay).Msg("Handling failed")
+
+	if delay > 0 {
+		err = m.NakWithDelay(delay)
+	} else {
+		err = m.Nak()
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("topic", m.Subject).Msg("Nak failed")
+	}
+}
@@ -0,0 +1,145 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func newPullTest(t *testing.T, maxDeliver int) (*NATS, nats.JetStreamContext) {
+	t.Helper()
+
+	nc := runNATS(t)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := NewJetStream(nc, js)
+	n.Pull = PullConfig{Stream: "FOO", MaxDeliver: maxDeliver, AckWait: time.Second, NakDelay: 10 * time.Millisecond,
+		BatchSize: 1, FetchWait: 100 * time.Millisecond, RetryWait: 50 * time.Millisecond}
+	t.Cleanup(func() { n.Close() })
+	return n, js
+}
+
+func addStream(t *testing.T, js nats.JetStreamContext) {
+	t.Helper()
+	_, err := js.AddStream(&nats.StreamConfig{Name: "FOO", Subjects: []string{"foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitAcked(t *testing.T, js nats.JetStreamContext, seq uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := js.ConsumerInfo("FOO", "multi")
+		if err == nil && info.AckFloor.Stream == seq {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message %d not acked, consumer state %+v, %v", seq, info, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestJetStreamPullRedelivers(t *testing.T) {
+	n, js := newPullTest(t, 3)
+	addStream(t, js)
+
+	var calls int32
+	err := n.Subscribe("foo", "multi", func(ctx context.Context, msg *Message) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("first try fails")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.Publish("foo", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitAcked(t, js, 1)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("handled %d times, want 2", n)
+	}
+}
+
+func TestJetStreamStopsAtMaxDeliver(t *testing.T) {
+	n, js := newPullTest(t, 2)
+	addStream(t, js)
+
+	var calls int32
+	err := n.Subscribe("foo", "multi", func(ctx context.Context, msg *Message) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("always fails")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.Publish("foo", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	n.Close()
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("handled %d times, want MaxDeliver 2", n)
+	}
+}
+
+// The publisher creates the stream, subscribers started earlier wait for it.
+// Closing keeps the durable consumer, so messages published meanwhile are not lost.
+func TestJetStreamWaitsForStreamAndKeepsConsumer(t *testing.T) {
+	n, js := newPullTest(t, 0)
+
+	handled := make(chan string, 2)
+	handler := func(ctx context.Context, msg *Message) error {
+		handled <- string(msg.Body)
+		return nil
+	}
+	err := n.Subscribe("foo", "multi", handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addStream(t, js)
+	js.Publish("foo", []byte("first"))
+
+	select {
+	case body := <-handled:
+		if body != "first" {
+			t.Errorf("handled %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing handled after the stream was created")
+	}
+	waitAcked(t, js, 1)
+	n.Close()
+
+	js.Publish("foo", []byte("second"))
+	again := NewJetStream(n.nc, js)
+	again.Pull = n.Pull
+	defer again.Close()
+	err = again.Subscribe("foo", "multi", handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case body := <-handled:
+		if body != "second" {
+			t.Errorf("handled %q after resubscribing, want only the new message", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message published while closed is lost")
+	}
+}
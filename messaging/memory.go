@@ -0,0 +1,98 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process broker for tests. Publish delivers synchronously,
+// to one subscriber per group in turn, and redelivers failed messages up
+// to MaxAttempts times.
+type Memory struct {
+	MaxAttempts int
+
+	mu        sync.Mutex
+	closed    bool
+	groups    map[string]map[string]*memoryGroup
+	published map[string][]*Message
+}
+
+type memoryGroup struct {
+	handlers []Handler
+	next     int
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		MaxAttempts: 1,
+		groups:      map[string]map[string]*memoryGroup{},
+		published:   map[string][]*Message{},
+	}
+}
+
+// Publish records msg and hands it to every group subscribed to topic.
+// Handler errors are not returned, as with a real broker.
+func (m *Memory) Publish(ctx context.Context, topic string, msg *Message) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+	m.published[topic] = append(m.published[topic], msg)
+
+	handlers := map[string]Handler{}
+	for name, g := range m.groups[topic] {
+		handlers[name] = g.handlers[g.next%len(g.handlers)]
+		g.next++
+	}
+	m.mu.Unlock()
+
+	now := time.Now()
+	for group, h := range handlers {
+		for attempt := 1; attempt <= m.MaxAttempts; attempt++ {
+			received := &Message{ID: msg.ID, Topic: topic, Header: Header{}, Body: msg.Body,
+				Group: group, Attempts: attempt, Timestamp: now}
+			for k, v := range msg.Header {
+				received.Header[k] = v
+			}
+			if h(ctx, received) == nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(topic, group string, h Handler) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+
+	if m.groups[topic] == nil {
+		m.groups[topic] = map[string]*memoryGroup{}
+	}
+	g, ok := m.groups[topic][group]
+	if !ok {
+		g = &memoryGroup{}
+		m.groups[topic][group] = g
+	}
+	g.handlers = append(g.handlers, h)
+	return nil
+}
+
+// Published returns the messages published to topic so far.
+func (m *Memory) Published(topic string) []*Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Message(nil), m.published[topic]...)
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
@@ -0,0 +1,159 @@
+// Package messaging hides whether a topic is served by NSQ, NATS or memory.
+//
+// Publishers and Subscribers move Messages, Middleware adds tracing, metrics,
+// logging and retries independent of the broker, and a Router picks the broker
+// of a topic from configuration.
+package messaging
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// IDHeader carries Message.ID for brokers without own message id.
+const IDHeader = "Msg-Id"
+
+// ErrClosed is returned when publishing to or subscribing on a closed adapter.
+var ErrClosed = errors.New("messaging: closed")
+
+// ErrNoReply is returned when responding to a message nobody waits for.
+var ErrNoReply = errors.New("messaging: message has no reply subject")
+
+// Message is what is published to and received from a topic.
+type Message struct {
+	// ID identifies the message across redeliveries and repeated publishes,
+	// consumers use it to skip duplicates. May be empty.
+	ID     string
+	Topic  string
+	Header Header
+	Body   []byte
+
+	// Group, Attempts and Timestamp are only set on received messages.
+	// Group is the group the message was received in. Attempts is how often
+	// the message was delivered, including this delivery. Timestamp is when
+	// the broker got the message, it is zero if the broker does not tell.
+	Group     string
+	Attempts  int
+	Timestamp time.Time
+
+	respond func(body []byte) error
+}
+
+// NewMessage creates a message with an empty header.
+func NewMessage(id string, body []byte) *Message {
+	return &Message{ID: id, Header: Header{}, Body: body}
+}
+
+// Respond answers a request received through NATS. It returns ErrNoReply
+// for all other messages.
+func (m *Message) Respond(body []byte) error {
+	if m.respond == nil {
+		return ErrNoReply
+	}
+	return m.respond(body)
+}
+
+// Header holds message metadata like the trace context.
+// It implements propagation.TextMapCarrier.
+type Header map[string]string
+
+func (h Header) Get(key string) string {
+	return h[key]
+}
+
+func (h Header) Set(key, value string) {
+	h[key] = value
+}
+
+func (h Header) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Publisher publishes messages to topics.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg *Message) error
+	Close() error
+}
+
+// Handler processes a received message. Returning an error asks the broker
+// to redeliver the message, as far as the broker supports it.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Subscriber delivers the messages of a topic to a handler. Every group
+// receives each message once; group is the NSQ channel or NATS queue group.
+// Core NATS also accepts an empty group, every such subscriber gets every message.
+type Subscriber interface {
+	Subscribe(topic, group string, h Handler) error
+	Close() error
+}
+
+type asyncKey struct{}
+
+// WithAsync asks publishers to return before the broker confirmed the message.
+// Publishers that cannot publish asynchronously ignore it.
+func WithAsync(ctx context.Context) context.Context {
+	return context.WithValue(ctx, asyncKey{}, true)
+}
+
+// IsAsync reports whether ctx was created by WithAsync.
+func IsAsync(ctx context.Context) bool {
+	async, _ := ctx.Value(asyncKey{}).(bool)
+	return async
+}
+
+// PublishFunc is the signature of Publisher.Publish.
+type PublishFunc func(ctx context.Context, topic string, msg *Message) error
+
+// PublishMiddleware wraps publishing.
+type PublishMiddleware func(next PublishFunc) PublishFunc
+
+// Middleware wraps a Handler.
+type Middleware func(next Handler) Handler
+
+// WrapHandler applies mws to h. The first middleware is the outermost.
+func WrapHandler(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// WrapPublisher applies mws to every publish of p. The first middleware is the outermost.
+func WrapPublisher(p Publisher, mws ...PublishMiddleware) Publisher {
+	publish := p.Publish
+	for i := len(mws) - 1; i >= 0; i-- {
+		publish = mws[i](publish)
+	}
+	return &wrappedPublisher{Publisher: p, publish: publish}
+}
+
+type wrappedPublisher struct {
+	Publisher
+	publish PublishFunc
+}
+
+func (p *wrappedPublisher) Publish(ctx context.Context, topic string, msg *Message) error {
+	if msg.Header == nil {
+		msg.Header = Header{}
+	}
+	return p.publish(ctx, topic, msg)
+}
+
+// WrapSubscriber applies mws to every handler subscribed through s.
+func WrapSubscriber(s Subscriber, mws ...Middleware) Subscriber {
+	return &wrappedSubscriber{Subscriber: s, mws: mws}
+}
+
+type wrappedSubscriber struct {
+	Subscriber
+	mws []Middleware
+}
+
+func (s *wrappedSubscriber) Subscribe(topic, group string, h Handler) error {
+	return s.Subscriber.Subscribe(topic, group, WrapHandler(h, s.mws...))
+}
@@ -0,0 +1,175 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryGroups(t *testing.T) {
+	m := NewMemory()
+	var got []string
+	record := func(name string) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			got = append(got, name+":"+string(msg.Body))
+			return nil
+		}
+	}
+	m.Subscribe("users", "audit", record("audit1"))
+	m.Subscribe("users", "audit", record("audit2"))
+	m.Subscribe("users", "mail", record("mail"))
+
+	ctx := context.Background()
+	m.Publish(ctx, "users", NewMessage("1", []byte("a")))
+	m.Publish(ctx, "users", NewMessage("2", []byte("b")))
+	m.Publish(ctx, "other", NewMessage("3", []byte("c")))
+
+	// Each group gets every message once, members of a group take turns.
+	counts := map[string]int{}
+	for _, g := range got {
+		counts[g]++
+	}
+	want := map[string]int{"audit1:a": 1, "audit2:b": 1, "mail:a": 1, "mail:b": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("deliveries = %v, want %v", counts, want)
+	}
+	if n := len(m.Published("users")); n != 2 {
+		t.Errorf("recorded %d messages, want 2", n)
+	}
+}
+
+func TestMemoryRedelivers(t *testing.T) {
+	m := NewMemory()
+	m.MaxAttempts = 3
+	var attempts []int
+	m.Subscribe("users", "audit", func(ctx context.Context, msg *Message) error {
+		attempts = append(attempts, msg.Attempts)
+		if msg.Attempts < 2 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	m.Publish(context.Background(), "users", NewMessage("1", nil))
+	if !reflect.DeepEqual(attempts, []int{1, 2}) {
+		t.Errorf("attempts = %v, want [1 2]", attempts)
+	}
+}
+
+func TestWrapOrder(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, msg *Message) error {
+				calls = append(calls, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+
+	m := NewMemory()
+	s := WrapSubscriber(m, mw("outer"), mw("inner"))
+	s.Subscribe("users", "audit", func(ctx context.Context, msg *Message) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	m.Publish(context.Background(), "users", NewMessage("1", nil))
+
+	if !reflect.DeepEqual(calls, []string{"outer", "inner", "handler"}) {
+		t.Errorf("calls = %v", calls)
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	got, err := ParseRoutes("users=nsq+nats, foo = nats,*=nsq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"users": {"nsq", "nats"}, "foo": {"nats"}, "*": {"nsq"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRoutes = %v, want %v", got, want)
+	}
+
+	for _, invalid := range []string{"users", "=nsq", "users=nsq+"} {
+		_, err := ParseRoutes(invalid)
+		if err == nil {
+			t.Errorf("%q accepted", invalid)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	nsq, nats := NewMemory(), NewMemory()
+	routes, _ := ParseRoutes("users=nsq+nats,foo=nats,*=nsq")
+	r, err := NewRouter(map[string]Publisher{"nsq": nsq, "nats": nats}, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, topic := range []string{"users", "foo", "default"} {
+		err := r.Publish(ctx, topic, NewMessage("", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(nsq.Published("users")) != 1 || len(nats.Published("users")) != 1 {
+		t.Error("users not published to both brokers")
+	}
+	if len(nsq.Published("foo")) != 0 || len(nats.Published("foo")) != 1 {
+		t.Error("foo not published to nats only")
+	}
+	if len(nsq.Published("default")) != 1 {
+		t.Error("default route not used")
+	}
+
+	_, err = NewRouter(map[string]Publisher{"nsq": nsq}, map[string][]string{"foo": {"kafka"}})
+	if err == nil {
+		t.Error("unknown broker accepted")
+	}
+}
+
+func TestLoadRoutes(t *testing.T) {
+	t.Setenv("MESSAGING_ROUTES", "default=nats,foo=nsq")
+	routes, err := LoadRoutes(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Routes{"*": {"nsq"}, "users": {"nsq", "nats"}, "foo": {"nsq"}, "default": {"nats"}}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("LoadRoutes = %v, want %v", routes, want)
+	}
+
+	t.Setenv("MESSAGING_ROUTES", "foo")
+	if _, err := LoadRoutes(false); err == nil {
+		t.Error("invalid MESSAGING_ROUTES accepted")
+	}
+}
+
+func TestRoutesSource(t *testing.T) {
+	routes := Routes{"*": {"nsq"}, "users": {"nsq", "nats"}, "foo": {"jetstream"}}
+	tests := []struct {
+		topic  string
+		prefer []string
+		want   string
+	}{
+		{"users", []string{"nats"}, "nats"},
+		{"users", []string{"nsq"}, "nsq"},
+		{"users", nil, "nsq"},
+		// A rerouted topic is consumed from its new broker.
+		{"foo", []string{"nsq"}, "jetstream"},
+		{"default", []string{"jetstream", "nats"}, "nsq"},
+	}
+	for _, tt := range tests {
+		got, err := routes.Source(tt.topic, tt.prefer...)
+		if err != nil || got != tt.want {
+			t.Errorf("Source(%s, %v) = %q, %v, want %q", tt.topic, tt.prefer, got, err, tt.want)
+		}
+	}
+
+	if _, err := (Routes{}).Source("users"); err == nil {
+		t.Error("topic without route has a source")
+	}
+}
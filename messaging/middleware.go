@@ -0,0 +1,283 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/******************************** Tracing ********************************/
+
+// PublishTracing runs each publish in a producer span and puts the trace
+// context into the message header, using the global propagator.
+func PublishTracing() PublishMiddleware {
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, msg *Message) error {
+			ctx, span := otel.Tracer("messaging").Start(ctx, topic+" publish",
+				trace.WithSpanKind(trace.SpanKindProducer),
+				trace.WithAttributes(attribute.String("messaging.destination", topic)))
+			defer span.End()
+
+			otel.GetTextMapPropagator().Inject(ctx, msg.Header)
+			err := next(ctx, topic, msg)
+			recordError(span, err)
+			return err
+		}
+	}
+}
+
+// HandlerTracing continues the trace found in the message header in a consumer span.
+func HandlerTracing() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, msg.Header)
+			ctx, span := otel.Tracer("messaging").Start(ctx, msg.Topic+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("messaging.destination", msg.Topic),
+					attribute.String("messaging.group", msg.Group),
+					attribute.String("messaging.message_id", msg.ID),
+					attribute.Int("messaging.attempts", msg.Attempts),
+				))
+			defer span.End()
+
+			err := next(ctx, msg)
+			recordError(span, err)
+			return err
+		}
+	}
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+/******************************** Metrics ********************************/
+
+// Metrics counts and times publishes and handled messages per topic.
+type Metrics struct {
+	published       *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	handled         *prometheus.CounterVec
+	handleDuration  *prometheus.HistogramVec
+}
+
+// NewMetrics creates the metrics with the service label and registers them with reg.
+func NewMetrics(reg prometheus.Registerer, service string) *Metrics {
+	labels := prometheus.Labels{"service": service}
+	m := &Metrics{
+		published: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "messaging_published_total",
+			Help:        "How many messages were published, partitioned by topic and result.",
+			ConstLabels: labels,
+		}, []string{"topic", "result"}),
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "messaging_publish_duration_seconds",
+			Help:        "How long publishing took, partitioned by topic.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 12),
+		}, []string{"topic"}),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "messaging_handled_total",
+			Help:        "How many received messages were handled, partitioned by topic, group and result.",
+			ConstLabels: labels,
+		}, []string{"topic", "group", "result"}),
+		handleDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "messaging_handle_duration_seconds",
+			Help:        "How long handling a message took, partitioned by topic and group.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"topic", "group"}),
+	}
+	reg.MustRegister(m.published, m.publishDuration, m.handled, m.handleDuration)
+	return m
+}
+
+func (m *Metrics) Publish() PublishMiddleware {
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, topic, msg)
+			m.publishDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+			m.published.WithLabelValues(topic, result(err)).Inc()
+			return err
+		}
+	}
+}
+
+func (m *Metrics) Handler() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			m.handleDuration.WithLabelValues(msg.Topic, msg.Group).Observe(time.Since(start).Seconds())
+			m.handled.WithLabelValues(msg.Topic, msg.Group, result(err)).Inc()
+			return err
+		}
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+/******************************** Logging ********************************/
+
+// PublishLogging logs failed publishes as warning and others as debug.
+func PublishLogging(logger zerolog.Logger) PublishMiddleware {
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, topic, msg)
+			event := logger.Debug()
+			if err != nil {
+				event = logger.Warn().Err(err)
+			}
+			event.Str("topic", topic).Str("id", msg.ID).Dur("duration", time.Since(start)).Msg("Publish")
+			return err
+		}
+	}
+}
+
+// HandlerLogging logs failed messages as warning and others as debug.
+func HandlerLogging(logger zerolog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			event := logger.Debug()
+			if err != nil {
+				event = logger.Warn().Err(err)
+			}
+			event.Str("topic", msg.Topic).Str("group", msg.Group).Str("id", msg.ID).Int("attempts", msg.Attempts).
+				Dur("duration", time.Since(start)).Msg("Handle")
+			return err
+		}
+	}
+}
+
+/********************************* Retry *********************************/
+
+// PermanentError marks an error retrying cannot fix.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent wraps err so that the retry middlewares give up immediately.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *PermanentError
+	return errors.As(err, &p)
+}
+
+// DelayedError asks the broker to redeliver the message after Delay.
+type DelayedError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *DelayedError) Error() string { return e.Err.Error() }
+func (e *DelayedError) Unwrap() error { return e.Err }
+
+// RetryAfter wraps err so that NSQ and JetStream redeliver the message after delay
+// instead of their default. Core NATS does not redeliver at all.
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &DelayedError{Err: err, Delay: delay}
+}
+
+// RetryDelay returns the delay err asks for with RetryAfter.
+func RetryDelay(err error) (time.Duration, bool) {
+	var d *DelayedError
+	if errors.As(err, &d) {
+		return d.Delay, true
+	}
+	return 0, false
+}
+
+// RetryPolicy retries up to Attempts times in total, waiting Backoff
+// doubled after every attempt but at most MaxBackoff.
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Exhausted reports whether a message may not be retried after the given attempt.
+// A zero Attempts retries forever.
+func (p RetryPolicy) Exhausted(attempt int) bool {
+	return p.Attempts > 0 && attempt >= p.Attempts
+}
+
+// Delay returns how long to wait after the given failed attempt, starting at 1.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+func (p RetryPolicy) do(ctx context.Context, f func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = f()
+		if err == nil || IsPermanent(err) || attempt >= p.Attempts {
+			return err
+		}
+
+		timer := time.NewTimer(p.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// PublishRetry retries failed publishes.
+func PublishRetry(p RetryPolicy) PublishMiddleware {
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, msg *Message) error {
+			return p.do(ctx, func() error { return next(ctx, topic, msg) })
+		}
+	}
+}
+
+// HandlerRetry retries the handler in process before the error reaches the broker.
+func HandlerRetry(p RetryPolicy) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			return p.do(ctx, func() error { return next(ctx, msg) })
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingAcrossBroker(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	m := NewMemory()
+	WrapSubscriber(m, HandlerTracing()).Subscribe("users", "audit", func(ctx context.Context, msg *Message) error {
+		return nil
+	})
+	p := WrapPublisher(m, PublishTracing())
+
+	err := p.Publish(context.Background(), "users", &Message{ID: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want publish and process", len(spans))
+	}
+	process, publish := spans[0], spans[1]
+	if process.Parent().SpanID() != publish.SpanContext().SpanID() {
+		t.Error("process span is not a child of the publish span")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics(prometheus.NewRegistry(), "test")
+	m := NewMemory()
+	WrapSubscriber(m, metrics.Handler()).Subscribe("users", "audit", func(ctx context.Context, msg *Message) error {
+		return errors.New("failed")
+	})
+	p := WrapPublisher(m, metrics.Publish())
+
+	p.Publish(context.Background(), "users", NewMessage("1", nil))
+
+	if n := testutil.ToFloat64(metrics.published.WithLabelValues("users", "ok")); n != 1 {
+		t.Errorf("published ok = %v, want 1", n)
+	}
+	if n := testutil.ToFloat64(metrics.handled.WithLabelValues("users", "audit", "error")); n != 1 {
+		t.Errorf("handled error = %v, want 1", n)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	calls := 0
+	h := WrapHandler(func(ctx context.Context, msg *Message) error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	}, HandlerRetry(policy))
+	if err := h(context.Background(), NewMessage("1", nil)); err != nil || calls != 3 {
+		t.Errorf("got %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	h = WrapHandler(func(ctx context.Context, msg *Message) error {
+		calls++
+		return Permanent(errors.New("invalid"))
+	}, HandlerRetry(policy))
+	if err := h(context.Background(), NewMessage("1", nil)); !IsPermanent(err) || calls != 1 {
+		t.Errorf("permanent error retried: %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	p := RetryPolicy{Attempts: 3}
+	if p.Exhausted(2) {
+		t.Error("Exhausted(2) = true, want false")
+	}
+	if !p.Exhausted(3) {
+		t.Error("Exhausted(3) = false, want true")
+	}
+	if (RetryPolicy{}).Exhausted(1000) {
+		t.Error("zero Attempts must retry forever")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	err := fmt.Errorf("handler: %w", RetryAfter(ErrInProgress, time.Minute))
+	if d, ok := RetryDelay(err); !ok || d != time.Minute {
+		t.Errorf("RetryDelay = %v, %t, want 1m", d, ok)
+	}
+	if !errors.Is(err, ErrInProgress) {
+		t.Error("RetryAfter hides the wrapped error")
+	}
+	if _, ok := RetryDelay(errors.New("failed")); ok {
+		t.Error("plain error has a delay")
+	}
+	if RetryAfter(nil, time.Minute) != nil {
+		t.Error("RetryAfter(nil) is not nil")
+	}
+}
@@ -0,0 +1,206 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATS publishes and subscribes with core NATS or, if created with
+// NewJetStream, through JetStream. The connection is owned by the caller.
+type NATS struct {
+	nc *nats.Conn
+	js nats.JetStreamContext
+
+	// PendingMsgs and PendingBytes limit how much a core NATS subscription buffers
+	// before it is a slow consumer and drops messages. Zero keeps the nats.go default.
+	PendingMsgs  int
+	PendingBytes int
+	// Pull configures the consumers of JetStream subscriptions.
+	Pull PullConfig
+	// Subscribed is called with every new subscription if set, e.g. to export its pending counts.
+	Subscribed func(sub *nats.Subscription)
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pulling sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	subs   []*nats.Subscription
+}
+
+func NewNATS(nc *nats.Conn) *NATS {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &NATS{nc: nc, Pull: DefaultPullConfig, ctx: ctx, cancel: cancel}
+}
+
+// NewJetStream publishes to the stream holding the topic and waits for its ack.
+// Subscribers are durable pull consumers named after their group, see PullConfig.
+func NewJetStream(nc *nats.Conn, js nats.JetStreamContext) *NATS {
+	n := NewNATS(nc)
+	n.js = js
+	return n
+}
+
+// Publish sends msg.ID as Nats-Msg-Id, which JetStream uses to drop duplicates.
+// Header keys are sent canonical like HTTP headers, as NATS headers are case-sensitive
+// and consumers extract the trace context with propagation.HeaderCarrier.
+func (n *NATS) Publish(ctx context.Context, topic string, msg *Message) error {
+	m := nats.NewMsg(topic)
+	for k, v := range msg.Header {
+		m.Header.Set(http.CanonicalHeaderKey(k), v)
+	}
+	if msg.ID != "" {
+		m.Header.Set(nats.MsgIdHdr, msg.ID)
+	}
+	m.Data = msg.Body
+
+	if n.js != nil {
+		_, err := n.js.PublishMsg(m, nats.Context(ctx))
+		return err
+	}
+	return n.nc.PublishMsg(m)
+}
+
+// Subscribe joins the queue group, or receives every message of topic if group
+// is empty. Failed messages are only redelivered with JetStream, which needs a group.
+func (n *NATS) Subscribe(topic, group string, h Handler) error {
+	n.mu.Lock()
+	closed := n.closed
+	n.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	if n.js != nil {
+		if group == "" {
+			return errors.New("messaging: JetStream subscriptions need a group")
+		}
+		n.pull(topic, group, h)
+		return nil
+	}
+
+	var sub *nats.Subscription
+	var err error
+	if group == "" {
+		sub, err = n.nc.Subscribe(topic, n.handle(group, h))
+	} else {
+		sub, err = n.nc.QueueSubscribe(topic, group, n.handle(group, h))
+	}
+	if err != nil {
+		return err
+	}
+
+	if n.PendingMsgs != 0 || n.PendingBytes != 0 {
+		msgs, bytes := n.PendingMsgs, n.PendingBytes
+		if msgs == 0 {
+			msgs = nats.DefaultSubPendingMsgsLimit
+		}
+		if bytes == 0 {
+			bytes = nats.DefaultSubPendingBytesLimit
+		}
+		err = sub.SetPendingLimits(msgs, bytes)
+		if err != nil {
+			sub.Unsubscribe()
+			return err
+		}
+	}
+	n.track(sub)
+	return nil
+}
+
+func (n *NATS) track(sub *nats.Subscription) {
+	n.mu.Lock()
+	n.subs = append(n.subs, sub)
+	n.mu.Unlock()
+	if n.Subscribed != nil {
+		n.Subscribed(sub)
+	}
+}
+
+func (n *NATS) handle(group string, h Handler) nats.MsgHandler {
+	return func(m *nats.Msg) {
+		msg := &Message{Topic: m.Subject, Header: Header{}, Body: m.Data, Group: group, Attempts: 1}
+		// Propagators use lower case keys.
+		for k := range m.Header {
+			msg.Header[strings.ToLower(k)] = m.Header.Get(k)
+		}
+		msg.ID = m.Header.Get(nats.MsgIdHdr)
+		if m.Reply != "" && n.js == nil {
+			msg.respond = m.Respond
+		}
+		if meta, err := m.Metadata(); err == nil {
+			msg.Attempts = int(meta.NumDelivered)
+			msg.Timestamp = meta.Timestamp
+		}
+
+		err := h(context.Background(), msg)
+		if n.js == nil {
+			if err != nil {
+				log.Warn().Err(err).Str("topic", m.Subject).Str("group", group).Msg("Handling failed, core NATS does not redeliver")
+			}
+			return
+		}
+		n.ack(m, err)
+	}
+}
+
+// Close stops the pull consumers and drains the other subscriptions made
+// through n, waiting up to the drain timeout of the connection until the
+// messages already received are handled. It does not close the connection.
+// JetStream consumers are kept, so the next subscriber continues where they stopped.
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	n.mu.Unlock()
+
+	n.cancel()
+	n.pulling.Wait()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var firstErr error
+	var draining []*nats.Subscription
+	for _, sub := range n.subs {
+		if sub.Type() == nats.PullSubscription {
+			continue
+		}
+		err := sub.Drain()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		draining = append(draining, sub)
+	}
+	n.subs = nil
+
+	// A drained subscription is removed once its handler returned.
+	deadline := time.Now().Add(n.nc.Opts.DrainTimeout)
+	for _, sub := range draining {
+		for sub.IsValid() && !n.nc.IsClosed() {
+			if time.Now().After(deadline) {
+				if firstErr == nil {
+					firstErr = nats.ErrDrainTimeout
+				}
+				return firstErr
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,235 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// nsqHeaderField holds the header inside JSON bodies, as NSQ messages have no headers.
+const nsqHeaderField = "headers"
+
+// NSQProducer is satisfied by *nsq.Producer and by producer pools.
+type NSQProducer interface {
+	Publish(topic string, body []byte) error
+}
+
+// NSQAsyncProducer is satisfied by producer pools that buffer publishes.
+type NSQAsyncProducer interface {
+	PublishAsync(topic string, body []byte) error
+}
+
+// NSQPublisher publishes through a producer owned by the caller.
+//
+// NSQ messages have no headers. If the body is a JSON object, the header and
+// ID are added as its "headers" field, which consumers decoding the body into
+// a struct simply ignore. Other bodies are published without header.
+type NSQPublisher struct {
+	producer NSQProducer
+}
+
+func NewNSQPublisher(producer NSQProducer) *NSQPublisher {
+	return &NSQPublisher{producer: producer}
+}
+
+// Publish publishes asynchronously if ctx was created by WithAsync and the
+// producer is an NSQAsyncProducer.
+func (p *NSQPublisher) Publish(ctx context.Context, topic string, msg *Message) error {
+	if async, ok := p.producer.(NSQAsyncProducer); ok && IsAsync(ctx) {
+		return async.PublishAsync(topic, encodeNSQ(msg))
+	}
+	return p.producer.Publish(topic, encodeNSQ(msg))
+}
+
+// Close does nothing, the producer belongs to the caller.
+func (p *NSQPublisher) Close() error {
+	return nil
+}
+
+func encodeNSQ(msg *Message) []byte {
+	header := Header{}
+	for k, v := range msg.Header {
+		header[k] = v
+	}
+	if msg.ID != "" {
+		header[IDHeader] = msg.ID
+	}
+	if len(header) == 0 {
+		return msg.Body
+	}
+
+	var fields map[string]json.RawMessage
+	if !isJSONObject(msg.Body) || json.Unmarshal(msg.Body, &fields) != nil {
+		return msg.Body
+	}
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return msg.Body
+	}
+	fields[nsqHeaderField] = raw
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return msg.Body
+	}
+	return body
+}
+
+// decodeNSQ reverses encodeNSQ. Bodies without header are returned unchanged.
+func decodeNSQ(body []byte) *Message {
+	msg := &Message{Header: Header{}, Body: body}
+	if !isJSONObject(body) {
+		return msg
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return msg
+	}
+	raw, ok := fields[nsqHeaderField]
+	if !ok {
+		return msg
+	}
+	var header Header
+	if json.Unmarshal(raw, &header) != nil {
+		return msg
+	}
+	delete(fields, nsqHeaderField)
+	stripped, err := json.Marshal(fields)
+	if err != nil {
+		return msg
+	}
+
+	msg.ID = header[IDHeader]
+	delete(header, IDHeader)
+	msg.Header = header
+	msg.Body = stripped
+	return msg
+}
+
+func isJSONObject(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("{"))
+}
+
+// NSQSubscriber creates one consumer per subscription, connected to nsqlookupd
+// if Lookupds are set and to NSQDs otherwise. Failed messages are requeued by
+// go-nsq, with the delay of RetryAfter errors and its default backoff otherwise.
+// Messages are touched while the handler runs, so slow handlers keep them.
+type NSQSubscriber struct {
+	Config   *nsq.Config
+	Lookupds []string
+	NSQDs    []string
+
+	// Concurrency is the number of handlers per subscription, at least one.
+	// Config.MaxInFlight should be at least as high.
+	Concurrency int
+	// Connect replaces connecting to Lookupds or NSQDs if set, e.g. to
+	// rewrite the addresses nsqlookupd returns or to export consumer stats.
+	Connect func(c *nsq.Consumer, topic, group string) error
+
+	mu        sync.Mutex
+	closed    bool
+	consumers []*nsq.Consumer
+}
+
+func NewNSQSubscriber(cfg *nsq.Config, lookupds, nsqds []string) *NSQSubscriber {
+	return &NSQSubscriber{Config: cfg, Lookupds: lookupds, NSQDs: nsqds}
+}
+
+// Subscribe consumes topic on the channel group.
+func (s *NSQSubscriber) Subscribe(topic, group string, h Handler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+
+	consumer, err := nsq.NewConsumer(topic, group, s.Config)
+	if err != nil {
+		return err
+	}
+	concurrency := s.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	consumer.AddConcurrentHandlers(nsqHandler(topic, group, s.Config.MsgTimeout/2, h), concurrency)
+
+	switch {
+	case s.Connect != nil:
+		err = s.Connect(consumer, topic, group)
+	case len(s.Lookupds) > 0:
+		err = consumer.ConnectToNSQLookupds(s.Lookupds)
+	default:
+		err = consumer.ConnectToNSQDs(s.NSQDs)
+	}
+	if err != nil {
+		consumer.Stop()
+		return err
+	}
+
+	s.consumers = append(s.consumers, consumer)
+	return nil
+}
+
+func nsqHandler(topic, group string, touchInterval time.Duration, h Handler) nsq.HandlerFunc {
+	return func(m *nsq.Message) error {
+		msg := decodeNSQ(m.Body)
+		msg.Topic = topic
+		msg.Group = group
+		msg.Attempts = int(m.Attempts)
+		msg.Timestamp = time.Unix(0, m.Timestamp)
+		if msg.ID == "" {
+			msg.ID = string(m.ID[:])
+		}
+
+		stopTouching := touch(m, touchInterval)
+		err := h(context.Background(), msg)
+		stopTouching()
+
+		if delay, ok := RetryDelay(err); ok {
+			// Only this message is delayed, the consumer itself does not back off.
+			m.DisableAutoResponse()
+			m.RequeueWithoutBackoff(delay)
+		}
+		return err
+	}
+}
+
+// touch keeps m alive in nsqd until the returned func is called.
+func touch(m *nsq.Message, interval time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				m.Touch()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Close stops all consumers and waits until their handlers returned.
+func (s *NSQSubscriber) Close() error {
+	s.mu.Lock()
+	consumers := s.consumers
+	s.consumers = nil
+	s.closed = true
+	s.mu.Unlock()
+
+	for _, c := range consumers {
+		c.Stop()
+	}
+	for _, c := range consumers {
+		<-c.StopChan
+	}
+	return nil
+}
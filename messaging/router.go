@@ -0,0 +1,157 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultRoute matches every topic without own route.
+const DefaultRoute = "*"
+
+// Routes maps topics to the brokers they are published to.
+type Routes map[string][]string
+
+// DefaultRoutes publishes users to NSQ and NATS and foo to NATS, or JetStream
+// if it is enabled. Every other topic goes to NSQ.
+func DefaultRoutes(jetStream bool) Routes {
+	routes := Routes{
+		DefaultRoute: {"nsq"},
+		"users":      {"nsq", "nats"},
+		"foo":        {"nats"},
+	}
+	if jetStream {
+		routes["foo"] = []string{"jetstream"}
+	}
+	return routes
+}
+
+// LoadRoutes returns DefaultRoutes with the topics listed in MESSAGING_ROUTES
+// replaced, see ParseRoutes. Publishers and consumers load the same routes,
+// so consumers follow a topic that is moved to another broker.
+func LoadRoutes(jetStream bool) (Routes, error) {
+	routes := DefaultRoutes(jetStream)
+	overrides, err := ParseRoutes(os.Getenv("MESSAGING_ROUTES"))
+	if err != nil {
+		return nil, fmt.Errorf("MESSAGING_ROUTES: %w", err)
+	}
+	for topic, names := range overrides {
+		routes[topic] = names
+	}
+	return routes, nil
+}
+
+// Route returns the brokers topic is published to.
+func (r Routes) Route(topic string) []string {
+	if names, ok := r[topic]; ok {
+		return names
+	}
+	return r[DefaultRoute]
+}
+
+// Source returns the broker a consumer receives topic from. A topic published
+// to several brokers is there several times, so it is consumed from the first
+// of prefer it is routed to, or else from the first broker routed.
+func (r Routes) Source(topic string, prefer ...string) (string, error) {
+	names := r.Route(topic)
+	if len(names) == 0 {
+		return "", fmt.Errorf("no broker routed for topic %s", topic)
+	}
+	for _, p := range prefer {
+		for _, name := range names {
+			if name == p {
+				return name, nil
+			}
+		}
+	}
+	return names[0], nil
+}
+
+// Router publishes each topic to the brokers routed to it, e.g. to switch a
+// topic from NSQ to NATS by configuration only.
+type Router struct {
+	brokers map[string]Publisher
+	routes  Routes
+}
+
+// NewRouter checks that every route names a known broker.
+func NewRouter(brokers map[string]Publisher, routes Routes) (*Router, error) {
+	for topic, names := range routes {
+		for _, name := range names {
+			if _, ok := brokers[name]; !ok {
+				return nil, fmt.Errorf("route %s: unknown broker %q", topic, name)
+			}
+		}
+	}
+	return &Router{brokers: brokers, routes: routes}, nil
+}
+
+// ParseRoutes parses "topic=broker" entries separated by commas. A topic
+// published to several brokers lists them separated by "+", e.g.
+// "users=nsq+nats,foo=nats,*=nsq".
+func ParseRoutes(s string) (map[string][]string, error) {
+	routes := map[string][]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		topic, brokers, ok := strings.Cut(entry, "=")
+		topic = strings.TrimSpace(topic)
+		if !ok || topic == "" {
+			return nil, fmt.Errorf("invalid route %q, want topic=broker", entry)
+		}
+
+		for _, name := range strings.Split(brokers, "+") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, fmt.Errorf("invalid route %q, empty broker", entry)
+			}
+			routes[topic] = append(routes[topic], name)
+		}
+	}
+	return routes, nil
+}
+
+// Route returns the brokers topic is published to.
+func (r *Router) Route(topic string) []string {
+	return r.routes.Route(topic)
+}
+
+// Publish publishes to every broker of the topic. It tries all of them and
+// returns the first error.
+func (r *Router) Publish(ctx context.Context, topic string, msg *Message) error {
+	names := r.Route(topic)
+	if len(names) == 0 {
+		return fmt.Errorf("no broker routed for topic %s", topic)
+	}
+
+	var firstErr error
+	for _, name := range names {
+		err := r.brokers[name].Publish(ctx, topic, msg)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return firstErr
+}
+
+// Close closes all brokers.
+func (r *Router) Close() error {
+	names := make([]string, 0, len(r.brokers))
+	for name := range r.brokers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var firstErr error
+	for _, name := range names {
+		err := r.brokers[name].Close()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"messaging"
+	"os"
+	"time"
+)
+
+// LoadPullConfig reads NATS_STREAM, NATS_MAX_DELIVER, NATS_ACK_WAIT, NATS_NAK_DELAY and NATS_BATCH_SIZE
+// for the durable pull consumers of the workers whose subject is routed to JetStream.
+func LoadPullConfig() messaging.PullConfig {
+	c := messaging.DefaultPullConfig
+	c.Stream = "FOO"
+	c.MaxDeliver = messaging.EnvInt("NATS_MAX_DELIVER", 5)
+	c.AckWait = messaging.EnvDuration("NATS_ACK_WAIT", 30*time.Second)
+	c.NakDelay = messaging.EnvDuration("NATS_NAK_DELAY", time.Second)
+	c.BatchSize = messaging.EnvInt("NATS_BATCH_SIZE", 10)
+	if stream := os.Getenv("NATS_STREAM"); stream != "" {
+		c.Stream = stream
+	}
+	return c
+}
@@ -1,65 +1,217 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"messaging"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"telemetry"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/go-redis/redis/v9"
 	"github.com/nats-io/nats.go"
+	"github.com/nsqio/go-nsq"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	service     = "natsconsumer"
+	environment = "development"
+	id          = 1
 )
 
 var NATS_URL = os.Getenv("NATS_URL")
+var CacheURL = os.Getenv("CACHE_URL") // Processed message ids are stored here.
+
+// JetStream makes the workers of subjects routed to JetStream durable pull consumers of the stream the backend publishes to.
+var JetStream = messaging.EnvBool("NATS_JETSTREAM", false)
+
+// NSQ_LOOKUP is only needed if MESSAGING_ROUTES moves a subject of a queue group to NSQ.
+var NSQ_LOOKUP = os.Getenv("NSQ_LOOKUP")
+
+// Subscriptions lists the workers as "subject:queue:workers", see ParseSubscribers.
+var Subscriptions = os.Getenv("NATS_SUBSCRIBERS")
+var DrainTimeout = messaging.EnvDuration("NATS_DRAIN_TIMEOUT", 30*time.Second)
+
+var rdb *redis.Client
+var metrics = NewMetrics(prometheus.DefaultRegisterer)
+var handlerMetrics = messaging.NewMetrics(prometheus.DefaultRegisterer, service)
+
+const (
+	claimTTL = time.Minute
+	doneTTL  = 24 * time.Hour
+)
 
 func main() {
 	// Connect to a server
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
-	for i := 0; i < 5; i++ {
-		MyQueueSubscribe(i, "foo", "multi")
+	configs, err := ParseSubscribers(Subscriptions)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	routes, err := messaging.LoadRoutes(JetStream)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	tp, err := telemetry.NewTracerProvider(context.Background(), telemetry.Config{Service: service, Environment: environment, ID: id})
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if CacheURL != "" {
+		rdb, err = ConnectRedis()
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+	} else {
+		log.Warn().Msg("CACHE_URL not set, duplicate messages will be processed again")
 	}
 
-	for i := 5; i < 10; i++ {
-		MyQueueSubscribe(i, "foo", "grp2")
+	closed := make(chan struct{})
+	opts := append(metrics.ConnectionOptions(closed),
+		nats.Name(service),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+		nats.RetryOnFailedConnect(true),
+		nats.DrainTimeout(DrainTimeout),
+	)
+	nc, err := nats.Connect(fmt.Sprintf("%s:4222", NATS_URL), opts...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
 	}
 
-	ReqListener(1)
-	ReqListener(2)
+	brokers, err := NewBrokers(nc)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	subs := NewSubscribers(routes, brokers, NewHandler)
+	err = subs.Start(configs)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
 
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		go http.ListenAndServe(":2112", nil)
-	}()
+	http.Handle("/metrics", promhttp.Handler())
+	go http.ListenAndServe(":2112", nil)
 
-	done := make(chan bool)
-	<-done
-}
+	// Listen for syscall signals for process to interrupt/quit
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	s := <-sig
+	log.Info().Msgf("received shutdown signal: %s", s)
 
-func MyQueueSubscribe(id int, subj, queue string) {
-	nc, err := nats.Connect(fmt.Sprintf("%s:4222", NATS_URL))
+	// The handlers finish the messages they already received
+	// before the connection is closed.
+	err = subs.Close()
 	if err != nil {
-		log.Error().Err(err).Msg("")
-		return
+		log.Warn().Err(err).Msg("")
 	}
-	log.Info().Str("URL", NATS_URL).Msg("Successfully connected.")
-	nc.QueueSubscribe(subj, queue, func(m *nats.Msg) {
-		log.Info().Int("ID", id).Str("Subject", subj).Str("queue", queue).Str("Message", string(m.Data)).Msg("")
-	})
-}
+	err = nc.Drain()
+	if err != nil {
+		log.Warn().Err(err).Msg("")
+	}
+	<-closed
 
-func ReqListener(id int) {
-	nc, err := nats.Connect(fmt.Sprintf("%s:4222", NATS_URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = tp.Shutdown(ctx)
 	if err != nil {
-		log.Error().Err(err).Msg("")
-		return
+		log.Warn().Err(err).Msg("")
+	}
+}
+
+// NewBrokers returns the subscribers of core NATS and, if enabled, of JetStream and NSQ.
+func NewBrokers(nc *nats.Conn) (map[string]messaging.Subscriber, error) {
+	core := messaging.NewNATS(nc)
+	core.PendingMsgs = messaging.EnvInt("NATS_PENDING_MSGS", nats.DefaultSubPendingMsgsLimit)
+	core.PendingBytes = messaging.EnvInt("NATS_PENDING_BYTES", nats.DefaultSubPendingBytesLimit)
+	core.Subscribed = metrics.Track
+	brokers := map[string]messaging.Subscriber{"nats": core}
+
+	if JetStream {
+		js, err := nc.JetStream()
+		if err != nil {
+			return nil, err
+		}
+		jetStream := messaging.NewJetStream(nc, js)
+		jetStream.Pull = LoadPullConfig()
+		jetStream.Subscribed = metrics.Track
+		brokers["jetstream"] = jetStream
 	}
-	log.Info().Str("URL", NATS_URL).Msg("Successfully connected.")
+	if NSQ_LOOKUP != "" {
+		brokers["nsq"] = messaging.NewNSQSubscriber(nsq.NewConfig(), []string{fmt.Sprintf("%s:4161", NSQ_LOOKUP)}, nil)
+	}
+	return brokers, nil
+}
+
+// NewHandler returns the handler of a worker. Queue group workers skip
+// duplicate messages, workers without queue group answer requests with their id.
+func NewHandler(w Worker) messaging.Handler {
+	middlewares := []messaging.Middleware{messaging.HandlerTracing(), handlerMetrics.Handler()}
+	if w.Queue != "" && rdb != nil {
+		middlewares = append(middlewares, dedups.get(w.Broker, w.Subject, w.Queue).Middleware())
+	}
+
+	return messaging.WrapHandler(func(ctx context.Context, msg *messaging.Message) error {
+		log.Info().Int("ID", w.ID).Str("Subject", msg.Topic).Str("queue", w.Queue).Str("Message", string(msg.Body)).
+			Str("trace-id", trace.SpanContextFromContext(ctx).TraceID().String()).Msg("")
+		if w.Queue == "" {
+			err := msg.Respond([]byte(fmt.Sprint(w.ID)))
+			if errors.Is(err, messaging.ErrNoReply) {
+				return nil
+			}
+			return err
+		}
+		return nil
+	}, middlewares...)
+}
 
-	nc.Subscribe("*", func(m *nats.Msg) {
-		nc.Publish(m.Reply, []byte(fmt.Sprint(id)))
-		log.Info().Int("ID", id).Str("Subject", "*").Str("Message", string(m.Data)).Msg("")
+// dedupSet shares one Deduplicator, and so one metric, per broker, subject and queue group.
+type dedupSet struct {
+	mu sync.Mutex
+	m  map[string]*messaging.Deduplicator
+}
+
+var dedups = dedupSet{m: map[string]*messaging.Deduplicator{}}
+
+func (s *dedupSet) get(broker, subj, queue string) *messaging.Deduplicator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := broker + " " + subj + " " + queue
+	d, ok := s.m[key]
+	if !ok {
+		d = messaging.NewDeduplicator(rdb, prometheus.DefaultRegisterer, broker, subj, queue, claimTTL, doneTTL)
+		s.m[key] = d
+	}
+	return d
+}
+
+func ConnectRedis() (*redis.Client, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:6379", CacheURL),
 	})
+
+	err := rdb.Ping(context.Background()).Err()
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to redis: %v", err)
+	}
+
+	log.Info().Msg("Successfully connected to Redis")
+	return rdb, nil
 }
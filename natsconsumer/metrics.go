@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics describes the NATS connection and subscriptions, partitioned by subject and queue group.
+// Subscribers without queue group use the queue label "none". How messages are handled is
+// counted by messaging_handled_total and messaging_handle_duration_seconds.
+type Metrics struct {
+	connected     prometheus.Gauge
+	disconnects   prometheus.Counter
+	reconnects    prometheus.Counter
+	slowConsumers *prometheus.CounterVec
+	subs          *subscriptionStats
+}
+
+// NewMetrics creates the connection metrics and registers them with reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	labels := []string{"subject", "queue"}
+	m := &Metrics{}
+
+	m.connected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "nats_connected",
+		Help: "Whether the connection to NATS is up (1) or not (0).",
+	})
+	m.disconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nats_disconnects_total",
+		Help: "How often the connection to NATS was lost.",
+	})
+	m.reconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nats_reconnects_total",
+		Help: "How often the connection to NATS was reestablished.",
+	})
+	m.slowConsumers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nats_slow_consumer_events_total",
+		Help: "How often a subscriber hit its pending limits and messages were dropped.",
+	}, labels)
+	subLabels := []string{"subject", "queue", "worker"}
+	m.subs = &subscriptionStats{
+		pendingMsgs:  prometheus.NewDesc("nats_subscription_pending_messages", "Messages waiting to be handled by the subscriber.", subLabels, nil),
+		pendingBytes: prometheus.NewDesc("nats_subscription_pending_bytes", "Bytes waiting to be handled by the subscriber.", subLabels, nil),
+		limitMsgs:    prometheus.NewDesc("nats_subscription_pending_messages_limit", "How many messages may be pending before the subscriber is a slow consumer.", subLabels, nil),
+		dropped:      prometheus.NewDesc("nats_subscription_dropped_messages_total", "Messages dropped because the pending limits were hit.", subLabels, nil),
+	}
+
+	reg.MustRegister(m.connected, m.disconnects, m.reconnects, m.slowConsumers, m.subs)
+	return m
+}
+
+// ConnectionOptions logs and counts connection events. closed is closed
+// once the connection is closed for good, e.g. after Drain.
+func (m *Metrics) ConnectionOptions(closed chan<- struct{}) []nats.Option {
+	return []nats.Option{
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			m.connected.Set(0)
+			m.disconnects.Inc()
+			log.Warn().Err(err).Msg("Disconnected from NATS")
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			m.connected.Set(1)
+			m.reconnects.Inc()
+			log.Info().Str("URL", nc.ConnectedUrl()).Msg("Reconnected to NATS")
+		}),
+		nats.ConnectHandler(func(nc *nats.Conn) {
+			m.connected.Set(1)
+			log.Info().Str("URL", nc.ConnectedUrl()).Msg("Successfully connected.")
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			m.connected.Set(0)
+			log.Info().Msg("NATS connection closed")
+			close(closed)
+		}),
+		nats.ErrorHandler(func(nc *nats.Conn, sub *nats.Subscription, err error) {
+			if sub == nil {
+				log.Warn().Err(err).Msg("NATS error")
+				return
+			}
+			if errors.Is(err, nats.ErrSlowConsumer) {
+				m.slowConsumers.WithLabelValues(sub.Subject, queueLabel(sub.Queue)).Inc()
+				msgs, bytes, _ := sub.Pending()
+				log.Warn().Str("Subject", sub.Subject).Str("queue", sub.Queue).Int("pending-msgs", msgs).Int("pending-bytes", bytes).Msg("Slow consumer, dropping messages")
+				return
+			}
+			log.Warn().Err(err).Str("Subject", sub.Subject).Str("queue", sub.Queue).Msg("NATS error")
+		}),
+	}
+}
+
+// Track exports the pending counts of sub. Subscriptions are numbered in the order they are tracked.
+func (m *Metrics) Track(sub *nats.Subscription) {
+	m.subs.mu.Lock()
+	defer m.subs.mu.Unlock()
+	worker := strconv.Itoa(len(m.subs.subs))
+	m.subs.subs = append(m.subs.subs, trackedSub{sub: sub, worker: worker})
+}
+
+type trackedSub struct {
+	sub    *nats.Subscription
+	worker string
+}
+
+// subscriptionStats reads the pending counts nats.go keeps per subscription.
+type subscriptionStats struct {
+	pendingMsgs  *prometheus.Desc
+	pendingBytes *prometheus.Desc
+	limitMsgs    *prometheus.Desc
+	dropped      *prometheus.Desc
+
+	mu   sync.Mutex
+	subs []trackedSub
+}
+
+func (s *subscriptionStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.pendingMsgs
+	ch <- s.pendingBytes
+	ch <- s.limitMsgs
+	ch <- s.dropped
+}
+
+func (s *subscriptionStats) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.subs {
+		labels := []string{t.sub.Subject, queueLabel(t.sub.Queue), t.worker}
+
+		// Closed subscriptions return errors and are skipped.
+		msgs, bytes, err := t.sub.Pending()
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(s.pendingMsgs, prometheus.GaugeValue, float64(msgs), labels...)
+		ch <- prometheus.MustNewConstMetric(s.pendingBytes, prometheus.GaugeValue, float64(bytes), labels...)
+
+		if limit, _, err := t.sub.PendingLimits(); err == nil {
+			ch <- prometheus.MustNewConstMetric(s.limitMsgs, prometheus.GaugeValue, float64(limit), labels...)
+		}
+		if dropped, err := t.sub.Dropped(); err == nil {
+			ch <- prometheus.MustNewConstMetric(s.dropped, prometheus.CounterValue, float64(dropped), labels...)
+		}
+	}
+}
+
+func queueLabel(queue string) string {
+	if queue == "" {
+		return "none"
+	}
+	return queue
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"messaging"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// The responder continues the trace of the request and its subscription is tracked.
+func TestNewHandler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ns := runServer(t)
+	m := NewMetrics(prometheus.NewRegistry())
+	nc, _ := connect(t, ns.ClientURL(), m)
+
+	subs := NewSubscribers(messaging.DefaultRoutes(false), coreNATS(nc, m), NewHandler)
+	defer subs.Close()
+	err := subs.Start([]SubscriberConfig{{"ping", "", 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	msg := nats.NewMsg("ping")
+	msg.Data = []byte("hello")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	reply, err := nc.RequestMsg(msg, time.Second)
+	span.End()
+	if err != nil || string(reply.Data) != "0" {
+		t.Fatalf("request = %v, %v, want the worker id 0", reply, err)
+	}
+
+	var found bool
+	for _, s := range recorder.Ended() {
+		if s.Parent().SpanID() == span.SpanContext().SpanID() {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("handler span is not a child of the request span")
+	}
+	if len(m.subs.subs) != 1 || m.subs.subs[0].worker != "0" {
+		t.Errorf("tracked %v, want the responder as worker 0", m.subs.subs)
+	}
+}
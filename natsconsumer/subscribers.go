@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"messaging"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultSubscribers is the subscriber set used if NATS_SUBSCRIBERS is not set.
+const DefaultSubscribers = "foo:multi:5,foo:grp2:5,*::2"
+
+// SubscriberConfig describes the workers of one subject and queue group.
+// Workers without queue group each receive every message and answer requests.
+type SubscriberConfig struct {
+	Subject string
+	Queue   string
+	Workers int
+}
+
+// ParseSubscribers parses "subject:queue:workers" entries separated by commas.
+// An empty s yields DefaultSubscribers.
+func ParseSubscribers(s string) ([]SubscriberConfig, error) {
+	if strings.TrimSpace(s) == "" {
+		s = DefaultSubscribers
+	}
+	var configs []SubscriberConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid subscriber %q, want subject:queue:workers", entry)
+		}
+		workers, err := strconv.Atoi(parts[2])
+		if err != nil || workers < 1 {
+			return nil, fmt.Errorf("invalid worker count in subscriber %q", entry)
+		}
+		configs = append(configs, SubscriberConfig{Subject: parts[0], Queue: parts[1], Workers: workers})
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no subscribers configured")
+	}
+	return configs, nil
+}
+
+// Worker identifies one subscription.
+type Worker struct {
+	ID      int
+	Subject string
+	Queue   string
+	Broker  string
+}
+
+// Subscribers runs all workers. Queue group workers consume their subject from
+// the broker MESSAGING_ROUTES publishes it to, workers without queue group
+// answer requests, which always go over core NATS.
+type Subscribers struct {
+	routes  messaging.Routes
+	brokers map[string]messaging.Subscriber
+	// Handler returns the message handler of a worker.
+	Handler func(w Worker) messaging.Handler
+}
+
+// NewSubscribers subscribes through brokers, which must contain "nats" for the requests.
+func NewSubscribers(routes messaging.Routes, brokers map[string]messaging.Subscriber, handler func(w Worker) messaging.Handler) *Subscribers {
+	return &Subscribers{
+		routes:  routes,
+		brokers: brokers,
+		Handler: handler,
+	}
+}
+
+// Start subscribes all workers. It fails if any subscription fails.
+func (s *Subscribers) Start(configs []SubscriberConfig) error {
+	id := 0
+	for _, c := range configs {
+		broker := "nats"
+		if c.Queue != "" {
+			var err error
+			broker, err = s.routes.Source(c.Subject, "jetstream", "nats")
+			if err != nil {
+				return err
+			}
+		}
+		sub, ok := s.brokers[broker]
+		if !ok {
+			return fmt.Errorf("%s is routed to %s, which is not configured", c.Subject, broker)
+		}
+
+		for i := 0; i < c.Workers; i++ {
+			w := Worker{ID: id, Subject: c.Subject, Queue: c.Queue, Broker: broker}
+			id++
+
+			err := sub.Subscribe(w.Subject, w.Queue, s.Handler(w))
+			if err != nil {
+				return fmt.Errorf("unable to subscribe worker %d to %s (queue %q): %w", w.ID, w.Subject, w.Queue, err)
+			}
+		}
+		log.Info().Str("Subject", c.Subject).Str("queue", c.Queue).Str("broker", broker).Int("workers", c.Workers).Msg("Subscribed")
+	}
+	return nil
+}
+
+// Close stops all workers once they handled the messages they already received.
+func (s *Subscribers) Close() error {
+	names := make([]string, 0, len(s.brokers))
+	for name := range s.brokers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var firstErr error
+	for _, name := range names {
+		err := s.brokers[name].Close()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"messaging"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseSubscribers(t *testing.T) {
+	got, err := ParseSubscribers(" foo:multi:5, *::2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SubscriberConfig{{"foo", "multi", 5}, {"*", "", 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSubscribers = %v, want %v", got, want)
+	}
+
+	got, err = ParseSubscribers("")
+	if err != nil || len(got) != 3 {
+		t.Errorf("empty config = %v, %v, want the default subscribers", got, err)
+	}
+
+	for _, invalid := range []string{"foo:multi", "foo:multi:0", ":multi:1", "foo:multi:x"} {
+		_, err := ParseSubscribers(invalid)
+		if err == nil {
+			t.Errorf("%q accepted", invalid)
+		}
+	}
+}
+
+// runServer starts an embedded nats-server with JetStream on a random port.
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats-server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns
+}
+
+// connect connects to ns the way main does and returns the channel closed after Drain.
+func connect(t *testing.T, url string, m *Metrics) (*nats.Conn, chan struct{}) {
+	t.Helper()
+	closed := make(chan struct{})
+	nc, err := nats.Connect(url, m.ConnectionOptions(closed)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	return nc, closed
+}
+
+func coreNATS(nc *nats.Conn, m *Metrics) map[string]messaging.Subscriber {
+	core := messaging.NewNATS(nc)
+	core.Subscribed = m.Track
+	return map[string]messaging.Subscriber{"nats": core}
+}
+
+func TestSubscribersClose(t *testing.T) {
+	ns := runServer(t)
+	m := NewMetrics(prometheus.NewRegistry())
+	nc, closed := connect(t, ns.ClientURL(), m)
+
+	var handled int32
+	release := make(chan struct{})
+	subs := NewSubscribers(messaging.DefaultRoutes(false), coreNATS(nc, m), func(w Worker) messaging.Handler {
+		return func(ctx context.Context, msg *messaging.Message) error {
+			if w.Queue == "" {
+				return msg.Respond([]byte("pong"))
+			}
+			<-release
+			atomic.AddInt32(&handled, 1)
+			return nil
+		}
+	})
+	err := subs.Start([]SubscriberConfig{{"foo", "multi", 3}, {"ping", "", 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := nc.Request("ping", nil, time.Second)
+	if err != nil || string(reply.Data) != "pong" {
+		t.Fatalf("request = %v, %v, want pong", reply, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		nc.Publish("foo", []byte("hello"))
+	}
+	nc.Flush()
+
+	// Messages already received are handled before the subscribers are closed.
+	done := make(chan error)
+	go func() { done <- subs.Close() }()
+	close(release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscribers not closed")
+	}
+	if n := atomic.LoadInt32(&handled); n != 3 {
+		t.Errorf("handled %d messages, want 3 (each once per queue group)", n)
+	}
+
+	err = nc.Drain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed after Drain")
+	}
+}
+
+// Queue group workers consume from the broker their subject is routed to.
+func TestSubscribersFollowRoutes(t *testing.T) {
+	ns := runServer(t)
+	m := NewMetrics(prometheus.NewRegistry())
+	nc, _ := connect(t, ns.ClientURL(), m)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{Name: "FOO", Subjects: []string{"foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	brokers := coreNATS(nc, m)
+	jetStream := messaging.NewJetStream(nc, js)
+	jetStream.Pull.FetchWait = 100 * time.Millisecond
+	brokers["jetstream"] = jetStream
+
+	handled := make(chan Worker, 1)
+	subs := NewSubscribers(messaging.DefaultRoutes(true), brokers, func(w Worker) messaging.Handler {
+		return func(ctx context.Context, msg *messaging.Message) error {
+			handled <- w
+			return nil
+		}
+	})
+	defer subs.Close()
+	err = subs.Start([]SubscriberConfig{{"foo", "multi", 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = js.Publish("foo", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case w := <-handled:
+		if w.Broker != "jetstream" {
+			t.Errorf("consumed from %s, want jetstream", w.Broker)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message published to JetStream not consumed")
+	}
+
+	err = subs.Start([]SubscriberConfig{{"bar", "multi", 1}})
+	if err == nil {
+		t.Error("subject routed to nsq subscribed without an NSQ subscriber")
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	ns := runServer(t)
+	m := NewMetrics(prometheus.NewRegistry())
+	nc, _ := connect(t, ns.ClientURL(), m)
+
+	release := make(chan struct{})
+	defer close(release)
+	core := messaging.NewNATS(nc)
+	core.PendingMsgs = 1
+	core.Subscribed = m.Track
+	subs := NewSubscribers(messaging.DefaultRoutes(false), map[string]messaging.Subscriber{"nats": core}, func(w Worker) messaging.Handler {
+		return func(context.Context, *messaging.Message) error {
+			<-release
+			return nil
+		}
+	})
+	err := subs.Start([]SubscriberConfig{{"foo", "multi", 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		nc.Publish("foo", []byte("hello"))
+	}
+	nc.Flush()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(m.slowConsumers.WithLabelValues("foo", "multi")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("slow consumer not detected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := testutil.CollectAndCount(m.subs, "nats_subscription_dropped_messages_total"); n != 1 {
+		t.Errorf("exported dropped counts for %d subscriptions, want 1", n)
+	}
+}
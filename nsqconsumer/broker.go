@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"messaging"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nsqio/go-nsq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewSubscriber connects to the broker NSQ_TOPIC is routed to: NSQ as conn says,
+// NATS and JetStream at NATS_URL. closeBroker closes the connection once the
+// subscriber is closed.
+func NewSubscriber(broker string, conn ConnectionConfig, stop <-chan struct{}, reg prometheus.Registerer) (s messaging.Subscriber, closeBroker func(), err error) {
+	switch broker {
+	case "nsq":
+		config := nsq.NewConfig()
+		// The handler dead-letters messages itself, go-nsq must not drop them first.
+		config.MaxAttempts = 0
+		// Without enough messages in flight the extra handlers would idle.
+		config.MaxInFlight = Concurrency
+
+		sub := messaging.NewNSQSubscriber(config, nil, nil)
+		sub.Concurrency = Concurrency
+		sub.Connect = func(c *nsq.Consumer, topic, channel string) error {
+			RegisterConsumerStats(reg, c, topic, channel)
+			return conn.Connect(c, topic, stop)
+		}
+		return sub, func() {}, nil
+
+	case "nats", "jetstream":
+		if NATS_URL == "" {
+			return nil, nil, fmt.Errorf("topic %s is routed to %s, but NATS_URL is not set", NSQ_TOPIC, broker)
+		}
+		nc, err := nats.Connect(fmt.Sprintf("%s:4222", NATS_URL), nats.Name(service), nats.MaxReconnects(-1), nats.RetryOnFailedConnect(true))
+		if err != nil {
+			return nil, nil, err
+		}
+		if broker == "nats" {
+			return messaging.NewNATS(nc), nc.Close, nil
+		}
+
+		js, err := nc.JetStream()
+		if err != nil {
+			nc.Close()
+			return nil, nil, err
+		}
+		sub := messaging.NewJetStream(nc, js)
+		// Redeliver dead-letters after NSQ_MAX_ATTEMPTS, JetStream must not drop messages first.
+		sub.Pull.MaxDeliver = 0
+		return sub, nc.Close, nil
+	}
+	return nil, nil, fmt.Errorf("topic %s is routed to unknown broker %q", NSQ_TOPIC, broker)
+}
@@ -0,0 +1,165 @@
+// nsqdlq inspects the dead-letter topic of an NSQ topic and optionally
+// replays its messages back onto the original topic.
+//
+//	nsqdlq -topic default                 # print dead-lettered messages, leave them queued
+//	nsqdlq -topic default -replay         # publish them to their topic again
+//
+// It connects to nsqd directly, so it also works from outside Docker.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"nsqconsumer/dlq"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/nsqio/go-nsq"
+)
+
+func main() {
+	nsqd := flag.String("nsqd", "localhost:4150", "nsqd TCP address")
+	topic := flag.String("topic", "default", "original topic whose dead letters are read")
+	channel := flag.String("channel", "dlq", "channel on the dead-letter topic")
+	replay := flag.Bool("replay", false, "publish the messages to their original topic and remove them from the dead-letter topic")
+	limit := flag.Int("n", 100, "maximum number of messages to read")
+	idle := flag.Duration("idle", 3*time.Second, "stop after no message arrived for this long")
+	flag.Parse()
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	config := nsq.NewConfig()
+	// Inspected messages are held until the end, so they are not redelivered meanwhile.
+	config.MaxInFlight = *limit
+	config.MaxAttempts = 0
+
+	consumer, err := nsq.NewConsumer(dlq.Topic(*topic), *channel, config)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	consumer.SetLoggerLevel(nsq.LogLevelWarning)
+
+	var producer *nsq.Producer
+	if *replay {
+		producer, err = nsq.NewProducer(*nsqd, nsq.NewConfig())
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+		defer producer.Stop()
+	}
+
+	// Held messages are requeued at the end: inspected ones, failed replays and
+	// the ones that arrived after the limit was reached.
+	var mu sync.Mutex
+	var held []*nsq.Message
+	var handled, replayed, failed int
+	var done bool
+	received := make(chan struct{}, *limit)
+
+	consumer.AddHandler(nsq.HandlerFunc(func(m *nsq.Message) error {
+		m.DisableAutoResponse()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if done {
+			m.RequeueWithoutBackoff(0)
+			return nil
+		}
+		if handled >= *limit {
+			held = append(held, m)
+			return nil
+		}
+
+		msg, err := dlq.Unmarshal(m.Body)
+		if err != nil {
+			log.Warn().Err(err).Msg("Skipping message that is not a dead letter")
+			held = append(held, m)
+			return nil
+		}
+
+		handled++
+		if handled == *limit {
+			// No more deliveries, finished messages would free their slot otherwise.
+			consumer.ChangeMaxInFlight(0)
+		}
+		printMessage(msg)
+
+		if *replay {
+			err = producer.Publish(msg.Topic, msg.Body)
+			if err != nil {
+				log.Error().Err(err).Str("topic", msg.Topic).Msg("Replay failed")
+				failed++
+				held = append(held, m)
+			} else {
+				replayed++
+				m.Finish()
+			}
+		} else {
+			held = append(held, m)
+		}
+
+		received <- struct{}{}
+		return nil
+	}))
+
+	err = consumer.ConnectToNSQD(*nsqd)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	count := 0
+wait:
+	for count < *limit {
+		select {
+		case <-received:
+			count++
+		case <-time.After(*idle):
+			break wait
+		case <-sigChan:
+			break wait
+		}
+	}
+
+	// Give held messages back to nsqd untouched, without getting them again.
+	consumer.ChangeMaxInFlight(0)
+	mu.Lock()
+	done = true
+	for _, m := range held {
+		m.RequeueWithoutBackoff(0)
+	}
+	held = nil
+	mu.Unlock()
+
+	consumer.Stop()
+	<-consumer.StopChan
+
+	if *replay {
+		log.Info().Msgf("Replayed %d messages onto %s", replayed, *topic)
+		if failed > 0 {
+			log.Warn().Msgf("%d replays failed, they stay in %s", failed, dlq.Topic(*topic))
+		}
+	} else {
+		log.Info().Msgf("Inspected %d messages of %s", count, dlq.Topic(*topic))
+	}
+}
+
+func printMessage(msg dlq.Message) {
+	body := string(msg.Body)
+	if !json.Valid(msg.Body) {
+		body = fmt.Sprintf("%q", msg.Body)
+	}
+
+	fmt.Printf("%s %s/%s attempts=%d failed_at=%s\n  reason: %s\n  body:   %s\n",
+		msg.ID, msg.Topic, msg.Channel, msg.Attempts, msg.FailedAt.Format(time.RFC3339), msg.Reason, body)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"messaging"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// Connection modes of the consumer.
+const (
+	// ModeLookupd discovers nsqd instances through nsqlookupd.
+	ModeLookupd = "lookupd"
+	// ModeNSQD connects to a static list of nsqd instances.
+	ModeNSQD = "nsqd"
+)
+
+const (
+	defaultLookupdPort = "4161"
+	defaultNSQDPort    = "4150"
+)
+
+// ConnectionConfig decides how the consumer finds its nsqd instances.
+//
+// nsqlookupd returns the broadcast address every nsqd registered with. Inside
+// Docker that is a container address the host cannot reach, which AddressMap
+// rewrites, e.g. "nsqd:4150" to "localhost:4150".
+type ConnectionConfig struct {
+	Mode         string
+	Lookupds     []string
+	NSQDs        []string
+	AddressMap   map[string]string
+	PollInterval time.Duration
+}
+
+// LoadConnectionConfig reads NSQ_MODE, NSQ_LOOKUP, NSQ_NSQDS, NSQ_ADDRESS_MAP and NSQ_LOOKUP_INTERVAL.
+// Addresses are comma separated, hosts without port get the default port.
+func LoadConnectionConfig() (ConnectionConfig, error) {
+	c := ConnectionConfig{
+		Mode:         os.Getenv("NSQ_MODE"),
+		Lookupds:     splitAddrs(os.Getenv("NSQ_LOOKUP"), defaultLookupdPort),
+		NSQDs:        splitAddrs(os.Getenv("NSQ_NSQDS"), defaultNSQDPort),
+		PollInterval: messaging.EnvDuration("NSQ_LOOKUP_INTERVAL", time.Minute),
+	}
+	if c.Mode == "" {
+		c.Mode = ModeLookupd
+	}
+	if c.PollInterval <= 0 {
+		return c, fmt.Errorf("NSQ_LOOKUP_INTERVAL must be positive, got %s", c.PollInterval)
+	}
+
+	var err error
+	c.AddressMap, err = parseAddressMap(os.Getenv("NSQ_ADDRESS_MAP"))
+	if err != nil {
+		return c, err
+	}
+
+	switch c.Mode {
+	case ModeLookupd:
+		if len(c.Lookupds) == 0 {
+			return c, errors.New("NSQ_LOOKUP not set")
+		}
+	case ModeNSQD:
+		if len(c.NSQDs) == 0 {
+			return c, errors.New("NSQ_NSQDS not set")
+		}
+	default:
+		return c, fmt.Errorf("unknown NSQ_MODE %q", c.Mode)
+	}
+	return c, nil
+}
+
+// Connect connects consumer according to the mode. In lookupd mode with an
+// address map, discovery runs in the background until stop is closed.
+func (c ConnectionConfig) Connect(consumer *nsq.Consumer, topic string, stop <-chan struct{}) error {
+	switch {
+	case c.Mode == ModeNSQD:
+		return consumer.ConnectToNSQDs(c.rewriteAll(c.NSQDs))
+
+	case len(c.AddressMap) == 0:
+		return consumer.ConnectToNSQLookupds(c.Lookupds)
+
+	default:
+		d := &lookupDiscovery{
+			lookupds: c.Lookupds,
+			topic:    topic,
+			rewrite:  c.rewrite,
+			client:   &http.Client{Timeout: 5 * time.Second},
+		}
+		err := d.connect(consumer)
+		if err != nil {
+			return err
+		}
+		go d.poll(consumer, c.PollInterval, stop)
+		return nil
+	}
+}
+
+// ProducerAddr returns where to publish dead letters: NSQ_DEMON if set, otherwise the first static nsqd.
+func (c ConnectionConfig) ProducerAddr(nsqd string) string {
+	addrs := splitAddrs(nsqd, defaultNSQDPort)
+	if len(addrs) == 0 {
+		addrs = c.NSQDs
+	}
+	if len(addrs) == 0 {
+		return ""
+	}
+	return c.rewrite(addrs[0])
+}
+
+func (c ConnectionConfig) rewrite(addr string) string {
+	if to, ok := c.AddressMap[addr]; ok {
+		return to
+	}
+	return addr
+}
+
+func (c ConnectionConfig) rewriteAll(addrs []string) []string {
+	out := make([]string, len(addrs))
+	for i, addr := range addrs {
+		out[i] = c.rewrite(addr)
+	}
+	return out
+}
+
+// lookupDiscovery replaces go-nsq's own lookupd polling so that the returned
+// broadcast addresses can be rewritten before connecting.
+type lookupDiscovery struct {
+	lookupds []string
+	topic    string
+	rewrite  func(string) string
+	client   *http.Client
+}
+
+type lookupProducer struct {
+	BroadcastAddress string `json:"broadcast_address"`
+	TCPPort          int    `json:"tcp_port"`
+}
+
+type lookupResponse struct {
+	Producers []lookupProducer `json:"producers"`
+}
+
+// discover asks every lookupd for the producers of the topic.
+// It only fails if no lookupd answered.
+func (d *lookupDiscovery) discover() ([]string, error) {
+	seen := map[string]bool{}
+	var addrs []string
+	var lastErr error
+	answered := 0
+
+	for _, lookupd := range d.lookupds {
+		producers, err := d.lookup(lookupd)
+		if err != nil {
+			lastErr = err
+			log.Warn().Err(err).Str("lookupd", lookupd).Msg("Lookup failed")
+			continue
+		}
+		answered++
+
+		for _, p := range producers {
+			addr := d.rewrite(net.JoinHostPort(p.BroadcastAddress, strconv.Itoa(p.TCPPort)))
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	if answered == 0 {
+		return nil, lastErr
+	}
+	return addrs, nil
+}
+
+func (d *lookupDiscovery) lookup(lookupd string) ([]lookupProducer, error) {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     lookupd,
+		Path:     "/lookup",
+		RawQuery: url.Values{"topic": {d.topic}}.Encode(),
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.nsq; version=1.0")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// nsqlookupd answers 404 until the topic exists.
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lookupd %s returned %s", lookupd, resp.Status)
+	}
+
+	var data lookupResponse
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+	return data.Producers, nil
+}
+
+func (d *lookupDiscovery) connect(consumer *nsq.Consumer) error {
+	addrs, err := d.discover()
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		err := consumer.ConnectToNSQD(addr)
+		if err != nil && err != nsq.ErrAlreadyConnected {
+			log.Warn().Err(err).Str("nsqd", addr).Msg("Unable to connect")
+			continue
+		}
+		if err == nil {
+			log.Info().Str("nsqd", addr).Msg("Connected")
+		}
+	}
+	return nil
+}
+
+func (d *lookupDiscovery) poll(consumer *nsq.Consumer, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := d.connect(consumer)
+			if err != nil {
+				log.Warn().Err(err).Msg("Discovery failed")
+			}
+		}
+	}
+}
+
+// splitAddrs splits a comma separated address list and adds port where missing.
+func splitAddrs(s, port string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(s, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, port)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// parseAddressMap parses "from=to,from=to" where both sides are host:port.
+func parseAddressMap(s string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid NSQ_ADDRESS_MAP entry %q, want from=to", pair)
+		}
+		m[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	}
+	return m, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitAddrs(t *testing.T) {
+	got := splitAddrs(" nsqlookupd, lookup2:4200,,127.0.0.1 ", defaultLookupdPort)
+	want := []string{"nsqlookupd:4161", "lookup2:4200", "127.0.0.1:4161"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitAddrs = %v, want %v", got, want)
+	}
+
+	if got := splitAddrs("", defaultNSQDPort); len(got) != 0 {
+		t.Errorf("splitAddrs(\"\") = %v, want none", got)
+	}
+}
+
+func TestParseAddressMap(t *testing.T) {
+	got, err := parseAddressMap("nsqd:4150=localhost:4150, nsqd2:4150 = localhost:4250")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"nsqd:4150": "localhost:4150", "nsqd2:4150": "localhost:4250"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAddressMap = %v, want %v", got, want)
+	}
+
+	_, err = parseAddressMap("nsqd:4150")
+	if err == nil {
+		t.Error("entry without '=' accepted")
+	}
+}
+
+func TestLookupDiscoveryRewrite(t *testing.T) {
+	lookupd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lookup" || r.URL.Query().Get("topic") != "default" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"channels":["links"],"producers":[
+			{"broadcast_address":"nsqd","tcp_port":4150},
+			{"broadcast_address":"10.0.0.7","tcp_port":4150}]}`))
+	}))
+	defer lookupd.Close()
+
+	unknownTopic := httptest.NewServer(http.NotFoundHandler())
+	defer unknownTopic.Close()
+
+	conn := ConnectionConfig{AddressMap: map[string]string{"nsqd:4150": "localhost:4150"}}
+	d := &lookupDiscovery{
+		lookupds: []string{strings.TrimPrefix(lookupd.URL, "http://"), strings.TrimPrefix(unknownTopic.URL, "http://")},
+		topic:    "default",
+		rewrite:  conn.rewrite,
+		client:   &http.Client{Timeout: time.Second},
+	}
+
+	got, err := d.discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"localhost:4150", "10.0.0.7:4150"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("discover = %v, want %v", got, want)
+	}
+}
+
+func TestProducerAddr(t *testing.T) {
+	conn := ConnectionConfig{NSQDs: []string{"127.0.0.1:4150"}}
+	if got := conn.ProducerAddr(""); got != "127.0.0.1:4150" {
+		t.Errorf("ProducerAddr without NSQ_DEMON = %q, want first nsqd", got)
+	}
+	if got := conn.ProducerAddr("nsqd"); got != "nsqd:4150" {
+		t.Errorf("ProducerAddr(nsqd) = %q, want nsqd:4150", got)
+	}
+}
+
+func TestLoadConnectionConfigInterval(t *testing.T) {
+	t.Setenv("NSQ_MODE", ModeLookupd)
+	t.Setenv("NSQ_LOOKUP", "nsqlookupd")
+
+	for _, interval := range []string{"0", "-1s"} {
+		t.Setenv("NSQ_LOOKUP_INTERVAL", interval)
+		_, err := LoadConnectionConfig()
+		if err == nil {
+			t.Errorf("NSQ_LOOKUP_INTERVAL=%s accepted", interval)
+		}
+	}
+
+	t.Setenv("NSQ_LOOKUP_INTERVAL", "30s")
+	c, err := LoadConnectionConfig()
+	if err != nil || c.PollInterval != 30*time.Second {
+		t.Errorf("got %v, %v", c.PollInterval, err)
+	}
+}
@@ -0,0 +1,42 @@
+// Package dlq defines the dead-letter topic naming and the envelope poison
+// messages are wrapped in, shared by the consumer and the nsqdlq tool.
+package dlq
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Suffix is appended to a topic to get its dead-letter topic.
+// NSQ only allows "#ephemeral" after a '#', so "<topic>#dlq" would be
+// rejected by nsqd; ".dlq" is the closest valid name.
+const Suffix = ".dlq"
+
+// Topic returns the dead-letter topic for topic.
+func Topic(topic string) string {
+	return topic + Suffix
+}
+
+// Message is a poison message together with why and where it failed.
+type Message struct {
+	Topic     string    `json:"topic"`
+	Channel   string    `json:"channel"`
+	ID        string    `json:"id"`
+	Attempts  uint16    `json:"attempts"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+	FailedAt  time.Time `json:"failed_at"`
+	Body      []byte    `json:"body"`
+}
+
+// Marshal encodes the message for publishing on the dead-letter topic.
+func (m Message) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Unmarshal decodes a message read from a dead-letter topic.
+func Unmarshal(body []byte) (Message, error) {
+	var m Message
+	err := json.Unmarshal(body, &m)
+	return m, err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"messaging"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Message types handled by this consumer.
+const (
+	TypeSimulated     = "simulated"
+	TypeUserCreated   = "user.created"
+	TypeUserLoggedIn  = "user.logged_in"
+	TypeUserDeleted   = "user.deleted"
+	maxSimulatedDelay = 5
+)
+
+// UserEvent is the payload of the user.* events relayed from the backend outbox.
+type UserEvent struct {
+	UserID string `json:"userid"`
+}
+
+// RegisterHandlers wires all message types to their handlers.
+// With simulate set, messages of unknown type get the simulated work handler,
+// which is what the plain messages produced by the backend's /protected page hit.
+func RegisterHandlers(r *Registry, timeout time.Duration, simulate bool) {
+	r.Handle(TypeUserCreated, timeout, HandleUserEvent)
+	r.Handle(TypeUserLoggedIn, timeout, HandleUserEvent)
+	r.Handle(TypeUserDeleted, timeout, HandleUserEvent)
+
+	r.Handle(TypeSimulated, timeout, SimulatedWork)
+	if simulate {
+		r.Fallback(timeout, SimulatedWork)
+	}
+}
+
+// HandleUserEvent processes the user events of the backend outbox.
+func HandleUserEvent(ctx context.Context, msg Message) error {
+	var event UserEvent
+	err := json.Unmarshal(msg.Data, &event)
+	if err != nil {
+		return messaging.Permanent(err)
+	}
+
+	log.Info().Str("type", msg.Type).Str("id", msg.ID).Str("userid", event.UserID).Msg("User event")
+	return nil
+}
+
+// SimulatedWork pretends to work by sleeping up to a few seconds.
+// It is only there for demos.
+func SimulatedWork(ctx context.Context, msg Message) error {
+	n := rand.Intn(maxSimulatedDelay)
+
+	select {
+	case <-time.After(time.Second * time.Duration(n)):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	log.Printf("%s\n", msg.Traceparent)
+	return nil
+}
@@ -5,23 +5,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"messaging"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"telemetry"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/go-redis/redis/v9"
 	"github.com/nsqio/go-nsq"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -30,17 +31,35 @@ const (
 	id          = 1
 )
 
-var NSQ_LOOKUP = os.Getenv("NSQ_LOOKUP")
 var NSQ_CHAN = os.Getenv("NSQ_CHAN")
 var NSQ_TOPIC = os.Getenv("NSQ_TOPIC")
-var Jaeger = os.Getenv("JAEGER_URL") //Port is 14268
+var NSQD = os.Getenv("NSQ_DEMON")     // Dead-lettered messages are published here.
+var CacheURL = os.Getenv("CACHE_URL") // Processed message ids are stored here.
+var DedupTTL = messaging.EnvDuration("NSQ_DEDUP_TTL", 24*time.Hour)
+
+// NATS_URL and NATS_JETSTREAM are only used if MESSAGING_ROUTES moves NSQ_TOPIC to NATS.
+var NATS_URL = os.Getenv("NATS_URL")
+var JetStream = messaging.EnvBool("NATS_JETSTREAM", false)
+
+var Retry = messaging.RetryPolicy{
+	Attempts:   messaging.EnvInt("NSQ_MAX_ATTEMPTS", 5),
+	Backoff:    messaging.EnvDuration("NSQ_BACKOFF_BASE", time.Second),
+	MaxBackoff: messaging.EnvDuration("NSQ_BACKOFF_MAX", time.Minute),
+}
+
+// Concurrency is the number of handlers working on this channel in parallel.
+var Concurrency = messaging.EnvInt("NSQ_CONCURRENCY", 1)
+var HandlerTimeout = messaging.EnvDuration("NSQ_HANDLER_TIMEOUT", 30*time.Second)
+
+// SimulateWork makes unknown message types sleep randomly instead of failing.
+var SimulateWork = messaging.EnvBool("NSQ_SIMULATE_WORK", true)
 
 func main() {
 
-	// For the random Delay.
+	// For the simulated work.
 	rand.Seed(time.Now().Unix())
 
-	tp, err := SetupTracerProvider()
+	tp, err := telemetry.NewTracerProvider(context.Background(), telemetry.Config{Service: service, Environment: environment, ID: id})
 	if err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
@@ -51,114 +70,140 @@ func main() {
 	// I think this is very important but I dont know why...
 	otel.SetTextMapPropagator(propagation.TraceContext{})
 
-	log.Info().Msgf("Starting consuming for Lookup: %s; Topic: %s; Channel:", "NSQ_CHAN", NSQ_LOOKUP, NSQ_TOPIC)
-	ConsumeMessage()
-}
-
-// SetupTracerProvider creates the Jaeger exporter.
-func SetupTracerProvider() (*tracesdk.TracerProvider, error) {
-	url := fmt.Sprintf("http://%s/api/traces", Jaeger)
-	// Create the Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
+	conn, err := LoadConnectionConfig()
 	if err != nil {
-		return nil, err
-	}
-	tp := tracesdk.NewTracerProvider(
-		// Always be sure to batch in production.
-		tracesdk.WithBatcher(exp),
-		// Record information about this application in a Resource.
-		tracesdk.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(service),
-			attribute.String("environment", environment),
-			attribute.Int64("ID", id),
-		)),
-	)
-	return tp, nil
-}
-
-type myMessageHandler struct{}
-
-// TODO this is stupid and will break someday....
-type Message struct {
-	Traceparent string
-}
-
-func (m Message) Get(key string) string {
-	if key == "traceparent" {
-		return m.Traceparent
+		log.Fatal().Err(err).Msg("")
 	}
-	return ""
+
+	log.Info().Msgf("Starting consuming in %s mode; Topic: %s; Channel: %s", conn.Mode, NSQ_TOPIC, NSQ_CHAN)
+	ConsumeMessage(conn)
 }
 
-func (m Message) Set(key string, value string) {
+type myMessageHandler struct {
+	registry *Registry
+	retry    messaging.RetryPolicy
+	dlq      *DeadLetterer
+	metrics  *Metrics
+	// claimWait is how long a message another consumer is processing waits before it is checked again.
+	claimWait time.Duration
 }
 
-func (m Message) Keys() []string {
-	return []string{"traceparent"}
+// Message is the decoded body of a message. Plain messages only carry a
+// traceparent, outbox events also have an id, a type and data.
+type Message struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Traceparent string          `json:"traceparent"`
+	Data        json.RawMessage `json:"data"`
 }
 
-// HandleMessage implements the Handler interface.
-func (h *myMessageHandler) HandleMessage(m *nsq.Message) error {
+// HandleMessage decodes msg and dispatches it by its type. Failures are
+// redelivered or dead-lettered by Redeliver.
+func (h *myMessageHandler) HandleMessage(ctx context.Context, msg *messaging.Message) error {
 
-	if len(m.Body) == 0 {
-		// Returning nil will automatically send a FIN command to NSQ to mark the message as processed.
-		// In this case, a message with an empty body is simply ignored/discarded.
+	if len(msg.Body) == 0 {
+		// A message with an empty body is simply ignored/discarded.
 		return nil
 	}
 
 	message := Message{}
 
-	err := json.Unmarshal(m.Body, &message)
+	err := json.Unmarshal(msg.Body, &message)
 	if err != nil {
-		log.Info().Err(err).Msg("")
+		return messaging.Permanent(err)
 	}
-	// log.Info().Msgf("Success!", message)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("message.type", message.Type))
 
-	propgator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	return h.registry.Dispatch(ctx, message)
+}
 
-	// carrier := propagation.TextMapCarrier{}
+// outboxTrace continues the trace of the request that stored an outbox event.
+// The header only carries the trace of the relay that published it.
+func outboxTrace(next messaging.Handler) messaging.Handler {
+	return func(ctx context.Context, msg *messaging.Message) error {
+		var message Message
+		if json.Unmarshal(msg.Body, &message) == nil && message.Traceparent != "" {
+			msg.Header.Set("traceparent", message.Traceparent)
+		}
+		return next(ctx, msg)
+	}
+}
 
-	parentCtx := propgator.Extract(context.Background(), message)
-	_, childSpan := otel.Tracer("foo").Start(parentCtx, "child-span-name")
-	defer childSpan.End()
+// ConsumeMessage consumes NSQ_TOPIC on NSQ_CHAN until SIGINT or SIGTERM, from the
+// broker MESSAGING_ROUTES publishes it to. nsqlookupd returns the Docker internal
+// addresses of nsqd, so outside of Docker either set NSQ_ADDRESS_MAP or connect
+// to NSQ_NSQDS directly.
+func ConsumeMessage(conn ConnectionConfig) {
 
-	// log.Info().Msgf(parentCtx)
-	// log.Info().Msgf(childSpan)
+	routes, err := messaging.LoadRoutes(JetStream)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	broker, err := routes.Source(NSQ_TOPIC, "nsq")
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
 
-	// do whatever actual message processing is desired
-	n := rand.Intn(5)
-	time.Sleep(time.Second * time.Duration(n))
+	producerAddr := conn.ProducerAddr(NSQD)
+	if producerAddr == "" {
+		log.Fatal().Msg("NSQ_DEMON not set!")
+	}
+	producer, err := nsq.NewProducer(producerAddr, nsq.NewConfig())
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
 
-	log.Printf("%s\n", message.Traceparent)
+	registry := NewRegistry()
+	RegisterHandlers(registry, HandlerTimeout, SimulateWork)
 
-	// Returning a non-nil error will automatically send a REQ command to NSQ to re-queue the message.
-	return nil
-}
+	handler := &myMessageHandler{
+		registry: registry,
+		retry:    Retry,
+		dlq:      NewDeadLetterer(messaging.NewNSQPublisher(producer)),
+		metrics:  NewMetrics(prometheus.DefaultRegisterer, NSQ_TOPIC, NSQ_CHAN),
+		// The other consumer is done within the handler timeout.
+		claimWait: HandlerTimeout,
+	}
+	middlewares := []messaging.Middleware{
+		outboxTrace,
+		messaging.HandlerTracing(),
+		messaging.NewMetrics(prometheus.DefaultRegisterer, service).Handler(),
+		handler.metrics.Handler(),
+		handler.Redeliver,
+	}
 
-// ConsumeMessage does currently not work!!
-// This is (guessed) because it polls the NSQLookup which works,
-// The Lookup returns the dockerinternal IP Addres to which the client has no acess too.
-// To work this either has all be in Docker or nothing at all....
-func ConsumeMessage() {
+	var rdb *redis.Client
+	if CacheURL != "" {
+		rdb, err = ConnectRedis()
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+		// A claim outlives the handler timeout, so a slow handler is not processed twice.
+		dedup := messaging.NewDeduplicator(rdb, prometheus.DefaultRegisterer, broker, NSQ_TOPIC, NSQ_CHAN, 2*HandlerTimeout, DedupTTL)
+		middlewares = append(middlewares, dedup.Middleware())
+	} else {
+		log.Warn().Msg("CACHE_URL not set, duplicate messages will be processed again")
+	}
 
-	// Instantiate a consumer that will subscribe to the provided channel.
-	config := nsq.NewConfig()
-	consumer, err := nsq.NewConsumer(NSQ_TOPIC, NSQ_CHAN, config)
+	stopDiscovery := make(chan struct{})
+	subscriber, closeBroker, err := NewSubscriber(broker, conn, stopDiscovery, prometheus.DefaultRegisterer)
 	if err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
 
-	// Set the Handler for messages received by this Consumer. Can be called multiple times.
-	// See also AddConcurrentHandlers.
-	consumer.AddHandler(&myMessageHandler{})
-
-	// Use nsqlookupd to discover nsqd instances.
-	// See also ConnectToNSQD, ConnectToNSQDs, ConnectToNSQLookupds.
-	err = consumer.ConnectToNSQLookupd(fmt.Sprintf("%s:4161", NSQ_LOOKUP))
-	if err != nil {
-		log.Fatal().Err(err).Msg("")
+	// NSQ runs the concurrent handlers itself, NATS one per subscription.
+	subscriptions := 1
+	if broker != "nsq" {
+		subscriptions = Concurrency
+	}
+	s := messaging.WrapSubscriber(subscriber, middlewares...)
+	for i := 0; i < subscriptions; i++ {
+		err = s.Subscribe(NSQ_TOPIC, NSQ_CHAN, handler.HandleMessage)
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
 	}
+	log.Info().Str("broker", broker).Strs("types", registry.Types()).Int("concurrency", Concurrency).Bool("simulate", SimulateWork).Msg("Registered handlers")
 
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
@@ -171,8 +216,31 @@ func ConsumeMessage() {
 	<-sigChan
 
 	// Gracefully stop the consumer.
-	consumer.Stop()
+	close(stopDiscovery)
+	err = subscriber.Close()
+	if err != nil {
+		log.Warn().Err(err).Msg("")
+	}
+	closeBroker()
+	producer.Stop()
+	if rdb != nil {
+		rdb.Close()
+	}
 
 	// os.Exit(1)
 
 }
+
+func ConnectRedis() (*redis.Client, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:6379", CacheURL),
+	})
+
+	err := rdb.Ping(context.Background()).Err()
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to redis: %v", err)
+	}
+
+	log.Info().Msg("Successfully connected to Redis")
+	return rdb, nil
+}
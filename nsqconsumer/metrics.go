@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"messaging"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics describes how this consumer redelivers messages, handled messages
+// are counted by messaging.Metrics. All metrics carry the topic and channel as labels.
+type Metrics struct {
+	requeued     prometheus.Counter
+	deadLettered prometheus.Counter
+
+	inflight prometheus.Gauge
+	age      prometheus.Histogram
+	attempts prometheus.Histogram
+}
+
+// NewMetrics creates the processing metrics and registers them with reg.
+func NewMetrics(reg prometheus.Registerer, topic, channel string) *Metrics {
+	labels := prometheus.Labels{"topic": topic, "channel": channel}
+	counter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help, ConstLabels: labels})
+	}
+
+	m := &Metrics{
+		requeued:     counter("nsq_messages_requeued_total", "How many messages were requeued to be retried."),
+		deadLettered: counter("nsq_messages_dead_lettered_total", "How many messages were moved to the dead-letter topic."),
+	}
+
+	m.inflight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "nsq_messages_in_flight",
+		Help:        "How many messages are processed right now.",
+		ConstLabels: labels,
+	})
+	m.age = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:        "nsq_message_age_seconds",
+		Help:        "How long ago a message was published when it was delivered.",
+		ConstLabels: labels,
+		Buckets:     prometheus.ExponentialBuckets(0.01, 4, 10),
+	})
+	m.attempts = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:        "nsq_message_attempts",
+		Help:        "How often a message was already delivered, including this delivery.",
+		ConstLabels: labels,
+		Buckets:     []float64{1, 2, 3, 5, 10, 20},
+	})
+
+	reg.MustRegister(m.requeued, m.deadLettered, m.inflight, m.age, m.attempts)
+	return m
+}
+
+// Handler counts the messages in flight and observes their age and attempts.
+func (m *Metrics) Handler() messaging.Middleware {
+	return func(next messaging.Handler) messaging.Handler {
+		return func(ctx context.Context, msg *messaging.Message) error {
+			m.attempts.Observe(float64(msg.Attempts))
+			if !msg.Timestamp.IsZero() {
+				m.age.Observe(time.Since(msg.Timestamp).Seconds())
+			}
+			m.inflight.Inc()
+			defer m.inflight.Dec()
+
+			return next(ctx, msg)
+		}
+	}
+}
+
+// consumerStats exports the counters go-nsq keeps itself.
+type consumerStats struct {
+	consumer *nsq.Consumer
+
+	received    *prometheus.Desc
+	finished    *prometheus.Desc
+	requeued    *prometheus.Desc
+	connections *prometheus.Desc
+}
+
+// RegisterConsumerStats exports consumer.Stats() as gauges, read on every scrape.
+func RegisterConsumerStats(reg prometheus.Registerer, consumer *nsq.Consumer, topic, channel string) {
+	labels := prometheus.Labels{"topic": topic, "channel": channel}
+
+	reg.MustRegister(&consumerStats{
+		consumer:    consumer,
+		received:    prometheus.NewDesc("nsq_consumer_messages_received", "Messages received according to go-nsq.", nil, labels),
+		finished:    prometheus.NewDesc("nsq_consumer_messages_finished", "Messages finished according to go-nsq.", nil, labels),
+		requeued:    prometheus.NewDesc("nsq_consumer_messages_requeued", "Messages requeued according to go-nsq.", nil, labels),
+		connections: prometheus.NewDesc("nsq_consumer_connections", "Open connections to nsqd.", nil, labels),
+	})
+}
+
+func (c *consumerStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.received
+	ch <- c.finished
+	ch <- c.requeued
+	ch <- c.connections
+}
+
+func (c *consumerStats) Collect(ch chan<- prometheus.Metric) {
+	stats := c.consumer.Stats()
+	ch <- prometheus.MustNewConstMetric(c.received, prometheus.GaugeValue, float64(stats.MessagesReceived))
+	ch <- prometheus.MustNewConstMetric(c.finished, prometheus.GaugeValue, float64(stats.MessagesFinished))
+	ch <- prometheus.MustNewConstMetric(c.requeued, prometheus.GaugeValue, float64(stats.MessagesRequeued))
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.Connections))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"messaging"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	m := NewMetrics(prometheus.NewRegistry(), "default", "links")
+
+	var inflight float64
+	handle := messaging.WrapHandler(func(ctx context.Context, msg *messaging.Message) error {
+		inflight = testutil.ToFloat64(m.inflight)
+		return nil
+	}, m.Handler())
+
+	handle(context.Background(), &messaging.Message{Attempts: 2, Timestamp: time.Now().Add(-time.Second)})
+	// Core NATS has no timestamp, the age is not observed then.
+	handle(context.Background(), &messaging.Message{Attempts: 1})
+
+	if inflight != 1 || testutil.ToFloat64(m.inflight) != 0 {
+		t.Errorf("in flight %v while handling and %v after, want 1 and 0", inflight, testutil.ToFloat64(m.inflight))
+	}
+	if n := sampleCount(t, m.age); n != 1 {
+		t.Errorf("observed the age of %d messages, want 1", n)
+	}
+	if n := sampleCount(t, m.attempts); n != 2 {
+		t.Errorf("observed the attempts of %d messages, want 2", n)
+	}
+}
+
+func sampleCount(t *testing.T, h prometheus.Histogram) uint64 {
+	t.Helper()
+	var m dto.Metric
+	err := h.Write(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
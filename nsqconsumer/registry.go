@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"messaging"
+	"time"
+)
+
+var ErrNoHandler = errors.New("no handler registered for message type")
+
+// HandlerFunc processes a single decoded message. The context is cancelled
+// once the handler's timeout expires.
+type HandlerFunc func(ctx context.Context, msg Message) error
+
+type route struct {
+	handle  HandlerFunc
+	timeout time.Duration
+}
+
+// Registry dispatches messages to handlers keyed by the message type.
+type Registry struct {
+	routes   map[string]route
+	fallback *route
+}
+
+func NewRegistry() *Registry {
+	return &Registry{routes: map[string]route{}}
+}
+
+// Handle registers h for messages of msgType. Registering a type twice replaces the handler.
+func (r *Registry) Handle(msgType string, timeout time.Duration, h HandlerFunc) {
+	r.routes[msgType] = route{handle: h, timeout: timeout}
+}
+
+// Fallback registers h for all messages without a handler of their own.
+func (r *Registry) Fallback(timeout time.Duration, h HandlerFunc) {
+	r.fallback = &route{handle: h, timeout: timeout}
+}
+
+// Types returns the message types with a dedicated handler.
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.routes))
+	for t := range r.routes {
+		types = append(types, t)
+	}
+	return types
+}
+
+// Dispatch runs the handler registered for msg.Type with its timeout applied to ctx.
+// Messages nobody handles fail permanently.
+func (r *Registry) Dispatch(ctx context.Context, msg Message) error {
+	rt, ok := r.routes[msg.Type]
+	if !ok {
+		if r.fallback == nil {
+			return messaging.Permanent(fmt.Errorf("%w: %q", ErrNoHandler, msg.Type))
+		}
+		rt = *r.fallback
+	}
+
+	if rt.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rt.timeout)
+		defer cancel()
+	}
+
+	err := rt.handle(ctx, msg)
+	if err == nil && ctx.Err() == context.DeadlineExceeded {
+		// The handler ignored its context; it still took too long.
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"messaging"
+	"testing"
+	"time"
+)
+
+func TestRegistryDispatch(t *testing.T) {
+	r := NewRegistry()
+
+	var got string
+	r.Handle("a", time.Second, func(ctx context.Context, msg Message) error {
+		got = "a:" + msg.ID
+		return nil
+	})
+
+	err := r.Dispatch(context.Background(), Message{ID: "1", Type: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "a:1" {
+		t.Errorf("handler got %q, want a:1", got)
+	}
+
+	err = r.Dispatch(context.Background(), Message{Type: "b"})
+	if !errors.Is(err, ErrNoHandler) || !messaging.IsPermanent(err) {
+		t.Errorf("unknown type: got %v, want permanent ErrNoHandler", err)
+	}
+
+	r.Fallback(time.Second, func(ctx context.Context, msg Message) error {
+		got = "fallback:" + msg.Type
+		return nil
+	})
+	err = r.Dispatch(context.Background(), Message{Type: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "fallback:b" {
+		t.Errorf("handler got %q, want fallback:b", got)
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	r := NewRegistry()
+	r.Handle("slow", 10*time.Millisecond, func(ctx context.Context, msg Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	r.Handle("ignores-ctx", 10*time.Millisecond, func(ctx context.Context, msg Message) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+
+	for _, typ := range []string{"slow", "ignores-ctx"} {
+		err := r.Dispatch(context.Background(), Message{Type: typ})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: got %v, want deadline exceeded", typ, err)
+		}
+		if messaging.IsPermanent(err) {
+			t.Errorf("%s: timeouts must be retried", typ)
+		}
+	}
+}
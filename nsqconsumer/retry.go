@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"messaging"
+	"nsqconsumer/dlq"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DeadLetterer forwards poison messages to the dead-letter topic.
+// Dead letters always go to NSQ, where nsqdlq reads them.
+type DeadLetterer struct {
+	publisher messaging.Publisher
+}
+
+func NewDeadLetterer(publisher messaging.Publisher) *DeadLetterer {
+	return &DeadLetterer{publisher: publisher}
+}
+
+// Send publishes m together with the reason it failed.
+func (d *DeadLetterer) Send(ctx context.Context, m *messaging.Message, reason error) error {
+	body, err := dlq.Message{
+		Topic:     m.Topic,
+		Channel:   m.Group,
+		ID:        m.ID,
+		Attempts:  uint16(m.Attempts),
+		Reason:    reason.Error(),
+		Timestamp: m.Timestamp,
+		FailedAt:  time.Now(),
+		Body:      m.Body,
+	}.Marshal()
+	if err != nil {
+		return err
+	}
+	return d.publisher.Publish(ctx, dlq.Topic(m.Topic), messaging.NewMessage("", body))
+}
+
+// Redeliver dead-letters messages that failed permanently or too often and asks
+// the broker to redeliver the others with the delay of the retry policy.
+// Only the message is delayed, the consumer itself does not back off.
+// Messages another consumer is processing are never dead-lettered, as they did not fail.
+func (h *myMessageHandler) Redeliver(next messaging.Handler) messaging.Handler {
+	return func(ctx context.Context, msg *messaging.Message) error {
+		err := next(ctx, msg)
+		if err == nil {
+			return nil
+		}
+
+		if errors.Is(err, messaging.ErrInProgress) {
+			log.Info().Str("id", msg.ID).Dur("delay", h.claimWait).Msg("Message in progress elsewhere, checking again later")
+			h.metrics.requeued.Inc()
+			return messaging.RetryAfter(err, h.claimWait)
+		}
+
+		if messaging.IsPermanent(err) || h.retry.Exhausted(msg.Attempts) {
+			log.Warn().Err(err).Int("attempts", msg.Attempts).Msg("Dead-lettering message")
+
+			dlqErr := h.dlq.Send(ctx, msg, err)
+			if dlqErr != nil {
+				// Keep the message rather than losing it.
+				log.Error().Err(dlqErr).Msg("Unable to dead-letter message")
+				h.metrics.requeued.Inc()
+				return messaging.RetryAfter(dlqErr, h.retry.MaxBackoff)
+			}
+			h.metrics.deadLettered.Inc()
+			return nil
+		}
+
+		delay := h.retry.Delay(msg.Attempts)
+		log.Info().Err(err).Int("attempts", msg.Attempts).Dur("delay", delay).Msg("Requeueing message")
+		h.metrics.requeued.Inc()
+		return messaging.RetryAfter(err, delay)
+	}
+}
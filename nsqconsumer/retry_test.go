@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"messaging"
+	"nsqconsumer/dlq"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestHandler(dlqBroker *messaging.Memory) *myMessageHandler {
+	registry := NewRegistry()
+	registry.Handle("ok", time.Second, func(ctx context.Context, msg Message) error { return nil })
+	registry.Handle("fail", time.Second, func(ctx context.Context, msg Message) error { return errors.New("boom") })
+
+	return &myMessageHandler{
+		registry:  registry,
+		retry:     messaging.RetryPolicy{Attempts: 5, Backoff: time.Second, MaxBackoff: time.Minute},
+		dlq:       NewDeadLetterer(dlqBroker),
+		metrics:   NewMetrics(prometheus.NewRegistry(), "default", "links"),
+		claimWait: 30 * time.Second,
+	}
+}
+
+func deliver(h *myMessageHandler, body string, attempts int) error {
+	handle := messaging.WrapHandler(h.HandleMessage, h.Redeliver)
+	return handle(context.Background(), &messaging.Message{
+		ID: "1", Topic: "default", Group: "links", Header: messaging.Header{}, Body: []byte(body), Attempts: attempts,
+	})
+}
+
+func TestRedeliver(t *testing.T) {
+	broker := messaging.NewMemory()
+	h := newTestHandler(broker)
+
+	if err := deliver(h, `{"type":"ok"}`, 1); err != nil {
+		t.Errorf("successful message: %v", err)
+	}
+
+	err := deliver(h, `{"type":"fail"}`, 3)
+	if delay, ok := messaging.RetryDelay(err); !ok || delay != 4*time.Second {
+		t.Errorf("failed message: %v, want redelivery after 4s", err)
+	}
+
+	// Exhausted and undecodable messages are dead-lettered.
+	if err := deliver(h, `{"type":"fail"}`, 5); err != nil {
+		t.Errorf("exhausted message: %v", err)
+	}
+	if err := deliver(h, `not json`, 1); err != nil {
+		t.Errorf("permanent failure: %v", err)
+	}
+
+	letters := broker.Published(dlq.Topic("default"))
+	if len(letters) != 2 {
+		t.Fatalf("dead-lettered %d messages, want 2", len(letters))
+	}
+	msg, err := dlq.Unmarshal(letters[0].Body)
+	if err != nil || msg.Topic != "default" || msg.Channel != "links" || msg.Attempts != 5 || msg.Reason != "boom" {
+		t.Errorf("dead letter %+v, %v", msg, err)
+	}
+
+	if n := testutil.ToFloat64(h.metrics.requeued); n != 1 {
+		t.Errorf("requeued = %v, want 1", n)
+	}
+	if n := testutil.ToFloat64(h.metrics.deadLettered); n != 2 {
+		t.Errorf("dead-lettered = %v, want 2", n)
+	}
+}
+
+func TestRedeliverKeepsUndeliverableDeadLetters(t *testing.T) {
+	broker := messaging.NewMemory()
+	broker.Close()
+	h := newTestHandler(broker)
+
+	err := deliver(h, `not json`, 1)
+	if delay, ok := messaging.RetryDelay(err); !ok || delay != time.Minute {
+		t.Errorf("got %v, want redelivery after the maximum backoff", err)
+	}
+	if n := testutil.ToFloat64(h.metrics.deadLettered); n != 0 {
+		t.Errorf("dead-lettered = %v, want 0", n)
+	}
+}
+
+// A message another consumer holds the claim of is redelivered even when its attempts are exhausted.
+func TestRedeliverInProgress(t *testing.T) {
+	broker := messaging.NewMemory()
+	h := newTestHandler(broker)
+	inProgress := func(ctx context.Context, msg *messaging.Message) error {
+		return messaging.ErrInProgress
+	}
+	handle := messaging.WrapHandler(inProgress, h.Redeliver)
+
+	err := handle(context.Background(), &messaging.Message{ID: "1", Topic: "default", Group: "links", Attempts: 5})
+	if delay, ok := messaging.RetryDelay(err); !ok || delay != 30*time.Second || !errors.Is(err, messaging.ErrInProgress) {
+		t.Errorf("got %v, want redelivery after the claim wait", err)
+	}
+	if letters := broker.Published(dlq.Topic("default")); len(letters) != 0 {
+		t.Errorf("dead-lettered %d messages in progress", len(letters))
+	}
+}
@@ -3,7 +3,13 @@ package proto
 // means to following:
 //		-I= source folder
 // 		--go_out= where to put the go files. Two points so that the compiler does not create a subfolder
+// 		--grpc-gateway_out= the REST gateway, see the google.api.http options
+// 		--openapiv2_out= the OpenAPI document of the gateway, merged into api.swagger.json
 // 		path to the file.
+//
+// google/api holds the annotations used by the gateway, copied from googleapis.
+//go:generate protoc -I=. --go_out=.. ./options.proto
 //go:generate protoc -I=. --go_out=.. ./addressbook.proto
-//go:generate protoc -I=. --go_out=.. --go-grpc_out=.. ./greeter.proto
-//go:generate protoc -I=. --go_out=.. --go-grpc_out=.. ./training.proto
+//go:generate protoc -I=. --go_out=.. --go-grpc_out=.. --grpc-gateway_out=.. ./greeter.proto
+//go:generate protoc -I=. --go_out=.. --go-grpc_out=.. --grpc-gateway_out=.. ./training.proto
+//go:generate protoc -I=. --openapiv2_out=. --openapiv2_opt=allow_merge=true,merge_file_name=api ./greeter.proto ./training.proto
@@ -0,0 +1,6 @@
+package proto
+
+// Sum adds up a, b and c without overflowing int32.
+func (n *Nums) Sum() int64 {
+	return int64(n.GetA()) + int64(n.GetB()) + int64(n.GetC())
+}
@@ -0,0 +1,9 @@
+package proto
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI v2 document of the REST gateway, generated from the
+// google.api.http annotations of greeter.proto and training.proto.
+//
+//go:embed api.swagger.json
+var OpenAPI []byte
@@ -0,0 +1,51 @@
+package proto
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Redacted replaces sensitive strings.
+const Redacted = "[REDACTED]"
+
+// Redact returns a copy of m without the fields marked with the sensitive option.
+// Sensitive strings are replaced by Redacted, all other sensitive fields are cleared.
+func Redact(m proto.Message) proto.Message {
+	c := proto.Clone(m)
+	if c != nil && c.ProtoReflect().IsValid() {
+		redact(c.ProtoReflect())
+	}
+	return c
+}
+
+func redact(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if proto.GetExtension(fd.Options(), E_Sensitive).(bool) {
+			if fd.Kind() == protoreflect.StringKind && fd.Cardinality() != protoreflect.Repeated {
+				m.Set(fd, protoreflect.ValueOfString(Redacted))
+			} else {
+				m.Clear(fd)
+			}
+			return true
+		}
+
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					redact(mv.Message())
+					return true
+				})
+			}
+		case fd.IsList():
+			if fd.Message() != nil {
+				for i := 0; i < v.List().Len(); i++ {
+					redact(v.List().Get(i).Message())
+				}
+			}
+		case fd.Message() != nil:
+			redact(v.Message())
+		}
+		return true
+	})
+}
@@ -0,0 +1,29 @@
+package proto
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	book := &AddressBook{People: []*Person{{
+		Name:   "Tim",
+		Email:  "tim@example.com",
+		Phones: []*Person_PhoneNumber{{Number: "123"}},
+	}}}
+
+	redacted := Redact(book).(*AddressBook)
+
+	p := redacted.People[0]
+	if p.Email != Redacted {
+		t.Errorf("email = %q", p.Email)
+	}
+	if p.Name != "Tim" || p.Phones[0].Number != "123" {
+		t.Errorf("redacted too much: %v", p)
+	}
+	if book.People[0].Email != "tim@example.com" {
+		t.Error("original was modified")
+	}
+
+	// Unset sensitive fields stay unset.
+	if Redact(&Person{Name: "Tim"}).(*Person).Email != "" {
+		t.Error("unset email was set")
+	}
+}
@@ -22,8 +22,13 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TrainerClient interface {
+	// Over REST the Trainings are sent as newline delimited JSON.
 	Train(ctx context.Context, opts ...grpc.CallOption) (Trainer_TrainClient, error)
 	FullRandom(ctx context.Context, opts ...grpc.CallOption) (Trainer_FullRandomClient, error)
+	// Aggregates the stored sessions of a user.
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	// Force of every iteration of one stored session.
+	ForceSeries(ctx context.Context, in *ForceSeriesRequest, opts ...grpc.CallOption) (*ForceSeries, error)
 }
 
 type trainerClient struct {
@@ -99,12 +104,35 @@ func (x *trainerFullRandomClient) Recv() (*Nums, error) {
 	return m, nil
 }
 
+func (c *trainerClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, "/proto.Trainer/stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trainerClient) ForceSeries(ctx context.Context, in *ForceSeriesRequest, opts ...grpc.CallOption) (*ForceSeries, error) {
+	out := new(ForceSeries)
+	err := c.cc.Invoke(ctx, "/proto.Trainer/forceSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TrainerServer is the server API for Trainer service.
 // All implementations must embed UnimplementedTrainerServer
 // for forward compatibility
 type TrainerServer interface {
+	// Over REST the Trainings are sent as newline delimited JSON.
 	Train(Trainer_TrainServer) error
 	FullRandom(Trainer_FullRandomServer) error
+	// Aggregates the stored sessions of a user.
+	Stats(context.Context, *StatsRequest) (*Stats, error)
+	// Force of every iteration of one stored session.
+	ForceSeries(context.Context, *ForceSeriesRequest) (*ForceSeries, error)
 	mustEmbedUnimplementedTrainerServer()
 }
 
@@ -118,6 +146,12 @@ func (UnimplementedTrainerServer) Train(Trainer_TrainServer) error {
 func (UnimplementedTrainerServer) FullRandom(Trainer_FullRandomServer) error {
 	return status.Errorf(codes.Unimplemented, "method FullRandom not implemented")
 }
+func (UnimplementedTrainerServer) Stats(context.Context, *StatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedTrainerServer) ForceSeries(context.Context, *ForceSeriesRequest) (*ForceSeries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ForceSeries not implemented")
+}
 func (UnimplementedTrainerServer) mustEmbedUnimplementedTrainerServer() {}
 
 // UnsafeTrainerServer may be embedded to opt out of forward compatibility for this service.
@@ -183,13 +217,58 @@ func (x *trainerFullRandomServer) Recv() (*Nums, error) {
 	return m, nil
 }
 
+func _Trainer_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrainerServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Trainer/stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrainerServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Trainer_ForceSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForceSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrainerServer).ForceSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Trainer/forceSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrainerServer).ForceSeries(ctx, req.(*ForceSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Trainer_ServiceDesc is the grpc.ServiceDesc for Trainer service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var Trainer_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.Trainer",
 	HandlerType: (*TrainerServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "stats",
+			Handler:    _Trainer_Stats_Handler,
+		},
+		{
+			MethodName: "forceSeries",
+			Handler:    _Trainer_ForceSeries_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "train",
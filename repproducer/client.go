@@ -4,6 +4,7 @@ import (
 	"context"
 	"math/rand"
 	"proto"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -65,8 +66,9 @@ func (c Client) doTrain() error {
 }
 
 func (c Client) fullRandom() error {
-	var serverSum int32
-	var serverMessages int32
+	// Written by the receiving goroutine while the sender reads them.
+	var serverSum atomic.Int64
+	var serverMessages atomic.Int64
 
 	stream, err := c.tc.FullRandom(context.Background())
 	if err != nil {
@@ -84,8 +86,8 @@ func (c Client) fullRandom() error {
 				if err != nil {
 					return
 				}
-				serverSum = serverSum + fr.A + fr.B + fr.C
-				serverMessages++
+				serverSum.Add(fr.Sum())
+				serverMessages.Add(1)
 			}
 
 		}
@@ -108,6 +110,6 @@ raus:
 		}
 	}
 
-	defer log.Info().Msgf("Client says: serverSum = %d with  %s, messages.", serverSum, serverMessages)
+	log.Info().Msgf("Client says: serverSum = %d with %d messages.", serverSum.Load(), serverMessages.Load())
 	return nil
 }
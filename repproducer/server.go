@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"proto"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -65,8 +66,9 @@ func (s *Server) Train(t proto.Trainer_TrainServer) error {
 }
 
 func (s *Server) FullRandom(t proto.Trainer_FullRandomServer) error {
-	var clientSum int32
-	var clientMessages int32
+	// Written by the receiving goroutine while the sender reads them.
+	var clientSum atomic.Int64
+	var clientMessages atomic.Int64
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 	defer cancel()
@@ -81,8 +83,8 @@ func (s *Server) FullRandom(t proto.Trainer_FullRandomServer) error {
 				if err != nil {
 					return
 				}
-				clientSum = clientSum + fr.A + fr.B + fr.C
-				clientMessages++
+				clientSum.Add(fr.Sum())
+				clientMessages.Add(1)
 			}
 
 		}
@@ -105,6 +107,6 @@ raus:
 		}
 	}
 
-	log.Info().Msgf("Server says: clientSum =", clientSum, "with ", clientMessages, "messages.")
+	log.Info().Msgf("Server says: clientSum = %d with %d messages.", clientSum.Load(), clientMessages.Load())
 	return nil
 }
@@ -0,0 +1,145 @@
+// Package telemetry creates the tracer provider of the services. The exporter is configured by the
+// standard OTEL_* environment variables:
+//   - OTEL_TRACES_EXPORTER: otlp (default), console or none
+//   - OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL: grpc (default) or http/protobuf
+//   - OTEL_EXPORTER_OTLP_(TRACES_)ENDPOINT, _HEADERS, _TIMEOUT, _COMPRESSION, _CERTIFICATE and _INSECURE
+//     are read by the OTLP exporters
+//   - OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the resource
+//   - OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG select the sampler, default parentbased_always_on
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// Exporter is otlp, console or none.
+var Exporter = os.Getenv("OTEL_TRACES_EXPORTER")
+
+// Protocol of the OTLP exporter, grpc or http/protobuf.
+var Protocol = firstEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL")
+
+// Endpoint of the OTLP exporter, the exporters read it themselves.
+var Endpoint = firstEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT")
+
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Config describes the service in the resource of its spans.
+type Config struct {
+	Service     string
+	Environment string
+	ID          int64
+}
+
+// NewTracerProvider creates a provider that batches the spans to the configured exporter.
+func NewTracerProvider(ctx context.Context, cfg Config) (*tracesdk.TracerProvider, error) {
+	res, err := NewResource(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := NewExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []tracesdk.TracerProviderOption{tracesdk.WithResource(res)}
+	if exp != nil {
+		// Always be sure to batch in production.
+		opts = append(opts, tracesdk.WithBatcher(exp))
+	}
+	return tracesdk.NewTracerProvider(opts...), nil
+}
+
+// NewMemoryProvider exports the spans synchronously into the returned exporter, for tests.
+func NewMemoryProvider(cfg Config) (*tracesdk.TracerProvider, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+	res, err := NewResource(context.Background(), cfg)
+	if err != nil {
+		res = resource.Empty()
+	}
+	return tracesdk.NewTracerProvider(tracesdk.WithSyncer(exp), tracesdk.WithResource(res)), exp
+}
+
+// NewResource describes the service, OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence.
+func NewResource(ctx context.Context, cfg Config) (*resource.Resource, error) {
+	return resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(
+			semconv.ServiceNameKey.String(cfg.Service),
+			attribute.String("environment", cfg.Environment),
+			attribute.Int64("ID", cfg.ID),
+		),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+}
+
+// NewExporter creates the exporter selected by Exporter and Protocol, nil for none.
+func NewExporter(ctx context.Context) (tracesdk.SpanExporter, error) {
+	switch Exporter {
+	case "", "otlp":
+	case "console":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", Exporter)
+	}
+
+	switch Protocol {
+	case "", "grpc":
+		return otlptracegrpc.New(ctx)
+	case "http/protobuf":
+		return otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q", Protocol)
+	}
+}
+
+// CollectorAddr is the host:port the OTLP exporter sends to, for health checks. It is empty
+// if the spans are not exported over OTLP.
+func CollectorAddr() string {
+	if Exporter != "" && Exporter != "otlp" {
+		return ""
+	}
+	port := "4317"
+	if Protocol == "http/protobuf" {
+		port = "4318"
+	}
+	if Endpoint == "" {
+		return net.JoinHostPort("localhost", port)
+	}
+
+	host := Endpoint
+	if strings.Contains(Endpoint, "://") {
+		u, err := url.Parse(Endpoint)
+		if err != nil {
+			return ""
+		}
+		host = u.Host
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return net.JoinHostPort(host, port)
+	}
+	return host
+}
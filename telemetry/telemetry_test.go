@@ -0,0 +1,169 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+)
+
+var testConfig = Config{Service: "backend", Environment: "test", ID: 1}
+
+func TestNewResource(t *testing.T) {
+	res, err := NewResource(context.Background(), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := res.Set().Value(semconv.ServiceNameKey); v.AsString() != "backend" {
+		t.Errorf("service = %v", v.AsString())
+	}
+
+	t.Setenv("OTEL_SERVICE_NAME", "gobackend")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "environment=production")
+	res, err = NewResource(context.Background(), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := res.Set().Value(semconv.ServiceNameKey); v.AsString() != "gobackend" {
+		t.Errorf("service = %v", v.AsString())
+	}
+	if v, _ := res.Set().Value(attribute.Key("environment")); v.AsString() != "production" {
+		t.Errorf("environment = %v", v.AsString())
+	}
+}
+
+func TestNewMemoryProvider(t *testing.T) {
+	tp, exp := NewMemoryProvider(testConfig)
+	_, span := tp.Tracer("test").Start(context.Background(), "work")
+	span.End()
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "work" {
+		t.Fatalf("spans = %v", spans)
+	}
+	if v, _ := spans[0].Resource.Set().Value(semconv.ServiceNameKey); v.AsString() != "backend" {
+		t.Errorf("service = %v", v.AsString())
+	}
+}
+
+// collector receives the spans over OTLP/gRPC.
+type collector struct {
+	coltracepb.UnimplementedTraceServiceServer
+	spans chan int
+}
+
+func (c *collector) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	n := 0
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			n += len(ss.Spans)
+		}
+	}
+	c.spans <- n
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// export sends a span with the configured exporter.
+func export(t *testing.T) {
+	t.Helper()
+
+	ctx := context.Background()
+	tp, err := NewTracerProvider(ctx, testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := tp.Tracer("test").Start(ctx, "work")
+	span.End()
+	err = tp.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOTLPGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &collector{spans: make(chan int, 1)}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, c)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	Exporter, Protocol = "", ""
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://"+lis.Addr().String())
+	export(t)
+	if n := <-c.spans; n != 1 {
+		t.Errorf("%d spans exported", n)
+	}
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		requests <- r
+	}))
+	defer srv.Close()
+
+	Exporter, Protocol = "otlp", "http/protobuf"
+	defer func() { Protocol = "" }()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+	export(t)
+	r := <-requests
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("%s %s %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+	}
+}
+
+func TestNewExporter(t *testing.T) {
+	defer func() { Exporter, Protocol = "", "" }()
+
+	Exporter = "none"
+	exp, err := NewExporter(context.Background())
+	if exp != nil || err != nil {
+		t.Errorf("none: %v, %v", exp, err)
+	}
+
+	Exporter = "jaeger"
+	_, err = NewExporter(context.Background())
+	if err == nil {
+		t.Error("jaeger exporter created")
+	}
+
+	Exporter, Protocol = "otlp", "http/json"
+	_, err = NewExporter(context.Background())
+	if err == nil {
+		t.Error("http/json exporter created")
+	}
+}
+
+func TestCollectorAddr(t *testing.T) {
+	defer func() { Exporter, Protocol, Endpoint = "", "", "" }()
+
+	for _, tt := range []struct {
+		exporter, protocol, endpoint string
+		addr                         string
+	}{
+		{"", "", "", "localhost:4317"},
+		{"otlp", "http/protobuf", "", "localhost:4318"},
+		{"", "", "http://jaegertracing:4317", "jaegertracing:4317"},
+		{"", "http/protobuf", "https://collector.example.com", "collector.example.com:4318"},
+		{"", "", "jaegertracing:4317", "jaegertracing:4317"},
+		{"none", "", "http://jaegertracing:4317", ""},
+		{"console", "", "", ""},
+	} {
+		Exporter, Protocol, Endpoint = tt.exporter, tt.protocol, tt.endpoint
+		if addr := CollectorAddr(); addr != tt.addr {
+			t.Errorf("%+v: %q", tt, addr)
+		}
+	}
+}
@@ -1,23 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"html"
 	"math/rand"
 	"net/http"
-	"os"
+	"telemetry"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
 
 const (
@@ -26,35 +22,11 @@ const (
 	id          = 1
 )
 
-var Jaeger = os.Getenv("JAEGER_URL") //Port is 14268
-
-// SetupTracerProvider creates the Jaeger exporter.
-func SetupTracerProvider() (*tracesdk.TracerProvider, error) {
-	url := fmt.Sprintf("http://%s/api/traces", Jaeger)
-	// Create the Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
-	if err != nil {
-		return nil, err
-	}
-	tp := tracesdk.NewTracerProvider(
-		// Always be sure to batch in production.
-		tracesdk.WithBatcher(exp),
-		// Record information about this application in a Resource.
-		tracesdk.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(service),
-			attribute.String("environment", environment),
-			attribute.Int64("ID", id),
-		)),
-	)
-	return tp, nil
-}
-
 func main() {
 
 	rand.Seed(time.Now().Unix())
 
-	tp, err := SetupTracerProvider()
+	tp, err := telemetry.NewTracerProvider(context.Background(), telemetry.Config{Service: service, Environment: environment, ID: id})
 	if err != nil {
 		log.Fatal().Err(err).Msg("")
 	}